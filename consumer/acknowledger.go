@@ -0,0 +1,208 @@
+package consumer
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+// BatchAcknowledger coalesces the acks of a consumer's channel into multiple acks. A multiple ack settles every
+// delivery up to its tag so only the longest run of settled deliveries (starting after the last one flushed) is
+// acked together, messages completed out of order wait for the ones before them. Nacks and rejects are sent right
+// away, they only count as settled.
+type batchAcknowledger struct {
+	channel  amqp.Acknowledger
+	size     int
+	floor    uint64          // every delivery up to floor is settled
+	settled  map[uint64]bool // settled deliveries above the floor, true while their ack wasn't sent
+	pending  int
+	started  bool
+	released bool
+	lock     *sync.Mutex
+}
+
+func newBatchAcknowledger(channel amqp.Acknowledger, size int) *batchAcknowledger {
+	return &batchAcknowledger{
+		channel: channel,
+		size:    size,
+		settled: make(map[uint64]bool),
+		lock:    &sync.Mutex{},
+	}
+}
+
+// Track records a delivery before it's handed out, the first one seeds the floor.
+func (ba *batchAcknowledger) track(tag uint64) {
+	ba.lock.Lock()
+	defer ba.lock.Unlock()
+
+	if !ba.started { // a pooled channel doesn't start its delivery tags at 1
+		ba.floor = tag - 1
+		ba.started = true
+	}
+}
+
+// Ack queues the ack of the delivery, flushing once the batch is full.
+func (ba *batchAcknowledger) Ack(tag uint64, multiple bool) error {
+	ba.lock.Lock()
+	defer ba.lock.Unlock()
+
+	if ba.released {
+		return errors.New("can't acknowledge, the consumer released the channel")
+	}
+
+	if multiple {
+		if err := ba.channel.Ack(tag, true); err != nil {
+			return err
+		}
+
+		ba.settleUpTo(tag)
+		return nil
+	}
+
+	ba.settled[tag] = true
+	ba.pending++
+	if ba.pending >= ba.size {
+		return ba.flush(false)
+	}
+
+	return nil
+}
+
+// Nack sends the nack right away.
+func (ba *batchAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	ba.lock.Lock()
+	defer ba.lock.Unlock()
+
+	if ba.released {
+		return errors.New("can't nack, the consumer released the channel")
+	}
+
+	if err := ba.channel.Nack(tag, multiple, requeue); err != nil {
+		return err
+	}
+
+	if multiple {
+		ba.settleUpTo(tag)
+	} else {
+		ba.settled[tag] = false
+	}
+
+	return nil
+}
+
+// Reject sends the reject right away.
+func (ba *batchAcknowledger) Reject(tag uint64, requeue bool) error {
+	ba.lock.Lock()
+	defer ba.lock.Unlock()
+
+	if ba.released {
+		return errors.New("can't reject, the consumer released the channel")
+	}
+
+	if err := ba.channel.Reject(tag, requeue); err != nil {
+		return err
+	}
+
+	ba.settled[tag] = false
+	return nil
+}
+
+// Flush sends the acks waiting to be sent. Without all, only the ones that can be coalesced into a multiple ack.
+func (ba *batchAcknowledger) Flush(all bool) error {
+	ba.lock.Lock()
+	defer ba.lock.Unlock()
+
+	if ba.released {
+		return nil
+	}
+
+	return ba.flush(all)
+}
+
+// Release flushes every ack waiting and stops acknowledging, the channel is about to be released.
+func (ba *batchAcknowledger) Release() error {
+	ba.lock.Lock()
+	defer ba.lock.Unlock()
+
+	if ba.released {
+		return nil
+	}
+
+	ba.released = true
+	return ba.flush(true)
+}
+
+// Discard stops acknowledging without flushing, the channel is already closed.
+func (ba *batchAcknowledger) Discard() {
+	ba.lock.Lock()
+	defer ba.lock.Unlock()
+
+	ba.released = true
+	ba.settled = make(map[uint64]bool)
+	ba.pending = 0
+}
+
+func (ba *batchAcknowledger) flush(all bool) error {
+
+	var last uint64 // last delivery of the settled run still waiting on its ack
+	tag := ba.floor + 1
+	for {
+		waiting, ok := ba.settled[tag]
+		if !ok {
+			break
+		}
+
+		if waiting {
+			last = tag
+			ba.pending--
+		}
+
+		delete(ba.settled, tag)
+		tag++
+	}
+
+	ba.floor = tag - 1
+
+	var firstErr error
+	if last > 0 {
+		firstErr = ba.channel.Ack(last, true)
+	}
+
+	if !all {
+		return firstErr
+	}
+
+	// Out of order acks go one by one, they stay settled so the run can grow past them later.
+	for tag, waiting := range ba.settled {
+		if !waiting {
+			continue
+		}
+
+		if err := ba.channel.Ack(tag, false); err != nil && firstErr == nil {
+			firstErr = err
+		}
+
+		ba.settled[tag] = false
+		ba.pending--
+	}
+
+	return firstErr
+}
+
+// SettleUpTo marks every delivery up to tag as settled after a multiple ack or nack.
+func (ba *batchAcknowledger) settleUpTo(tag uint64) {
+	for settledTag, waiting := range ba.settled {
+		if settledTag <= tag {
+			if waiting {
+				ba.pending--
+			}
+
+			delete(ba.settled, settledTag)
+		}
+	}
+
+	if tag > ba.floor {
+		ba.floor = tag
+	}
+}
@@ -0,0 +1,144 @@
+package consumer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RecordingAcknowledger records what reaches the channel.
+type recordingAcknowledger struct {
+	calls []string
+}
+
+func (ra *recordingAcknowledger) Ack(tag uint64, multiple bool) error {
+	ra.calls = append(ra.calls, fmt.Sprintf("ack %d %t", tag, multiple))
+	return nil
+}
+
+func (ra *recordingAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	ra.calls = append(ra.calls, fmt.Sprintf("nack %d %t", tag, multiple))
+	return nil
+}
+
+func (ra *recordingAcknowledger) Reject(tag uint64, requeue bool) error {
+	ra.calls = append(ra.calls, fmt.Sprintf("reject %d", tag))
+	return nil
+}
+
+func TestBatchAcknowledgerFlush(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		firstTag  uint64
+		delivered uint64
+		acks      []uint64
+		nacks     []uint64
+		all       bool
+		calls     []string
+		floor     uint64
+		pending   int
+	}{
+		{
+			name:      "in order",
+			firstTag:  1,
+			delivered: 3,
+			acks:      []uint64{1, 2, 3},
+			calls:     []string{"ack 3 true"},
+			floor:     3,
+		},
+		{
+			name:      "pooled channel starting past 1",
+			firstTag:  101,
+			delivered: 2,
+			acks:      []uint64{101, 102},
+			calls:     []string{"ack 102 true"},
+			floor:     102,
+		},
+		{
+			name:      "out of order waits on the first",
+			firstTag:  101,
+			delivered: 3,
+			acks:      []uint64{102, 103},
+			calls:     []string{},
+			floor:     100,
+			pending:   2,
+		},
+		{
+			name:      "out of order completes the run",
+			firstTag:  101,
+			delivered: 3,
+			acks:      []uint64{103, 102, 101},
+			calls:     []string{"ack 103 true"},
+			floor:     103,
+		},
+		{
+			name:      "nack in the run",
+			firstTag:  1,
+			delivered: 3,
+			acks:      []uint64{1, 3},
+			nacks:     []uint64{2},
+			calls:     []string{"nack 2 false", "ack 3 true"},
+			floor:     3,
+		},
+		{
+			name:      "run ending on a nack",
+			firstTag:  1,
+			delivered: 3,
+			acks:      []uint64{1},
+			nacks:     []uint64{2},
+			calls:     []string{"nack 2 false", "ack 1 true"},
+			floor:     2,
+		},
+		{
+			name:      "all acks the out of order ones alone",
+			firstTag:  1,
+			delivered: 3,
+			acks:      []uint64{1, 3},
+			all:       true,
+			calls:     []string{"ack 1 true", "ack 3 false"},
+			floor:     1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			channel := &recordingAcknowledger{calls: []string{}}
+			batch := newBatchAcknowledger(channel, 100)
+
+			for tag := test.firstTag; tag < test.firstTag+test.delivered; tag++ {
+				batch.track(tag)
+			}
+
+			for _, tag := range test.nacks {
+				assert.NoError(t, batch.Nack(tag, false, true))
+			}
+
+			for _, tag := range test.acks {
+				assert.NoError(t, batch.Ack(tag, false))
+			}
+
+			assert.NoError(t, batch.Flush(test.all))
+			assert.Equal(t, test.calls, channel.calls)
+			assert.Equal(t, test.floor, batch.floor)
+			assert.Equal(t, test.pending, batch.pending)
+		})
+	}
+}
+
+func TestBatchAcknowledgerFullBatch(t *testing.T) {
+
+	channel := &recordingAcknowledger{calls: []string{}}
+	batch := newBatchAcknowledger(channel, 2)
+	batch.track(11)
+
+	assert.NoError(t, batch.Ack(11, false))
+	assert.Equal(t, []string{}, channel.calls)
+
+	assert.NoError(t, batch.Ack(12, false))
+	assert.Equal(t, []string{"ack 12 true"}, channel.calls)
+
+	assert.NoError(t, batch.Release())
+	assert.Error(t, batch.Ack(13, false))
+}
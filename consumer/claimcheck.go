@@ -0,0 +1,79 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+	"github.com/prom3t3us/turbocookedrabbit/utils"
+)
+
+// SetBlobStore sets where claim-checked Bodies are fetched from, they're inlined before the Message is delivered.
+// Nil delivers claim-checked Messages as they are (empty Body, key in the ClaimCheckHeader).
+func (con *Consumer) SetBlobStore(store utils.BlobStore) {
+	con.blobStoreLock.Lock()
+	defer con.blobStoreLock.Unlock()
+
+	con.blobStore = store
+}
+
+func (con *Consumer) getBlobStore() utils.BlobStore {
+	con.blobStoreLock.RLock()
+	defer con.blobStoreLock.RUnlock()
+
+	return con.blobStore
+}
+
+// InlineClaimCheck fetches the Body of a claim-checked Message from the BlobStore, giving up once ctx is done.
+// Returns false, after rejecting the Message and reporting the error, when the Body can't be fetched. A Message
+// given up on is requeued instead, the subscription it arrived on has ended.
+func (con *Consumer) inlineClaimCheck(ctx context.Context, msg *models.Message) bool {
+
+	key, ok := msg.ClaimCheckKey()
+	if !ok {
+		return true
+	}
+
+	store := con.getBlobStore()
+	if store == nil {
+		return true
+	}
+
+	body, err := store.Get(ctx, key)
+	if err != nil && ctx.Err() != nil {
+		if msg.IsAckable {
+			_ = msg.Nack(true)
+		}
+
+		return false
+	}
+
+	if err != nil {
+		if msg.IsAckable {
+			_ = msg.Reject(false)
+		}
+
+		con.handleError(fmt.Errorf("can't fetch claim-checked body %s: %s", key, err))
+		return false
+	}
+
+	msg.Body = body
+	return true
+}
+
+// CleanupClaimCheck deletes the claim-checked Body of the Message from the BlobStore, call it once the Message has
+// been acknowledged. Does nothing for Messages that weren't claim-checked.
+func (con *Consumer) CleanupClaimCheck(msg *models.Message) error {
+
+	key, ok := msg.ClaimCheckKey()
+	if !ok {
+		return nil
+	}
+
+	store := con.getBlobStore()
+	if store == nil {
+		return fmt.Errorf("can't delete claim-checked body %s, consumer has no blob store", key)
+	}
+
+	return store.Delete(key)
+}
@@ -0,0 +1,38 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+)
+
+// BlockingBlobStore is a BlobStore whose Get waits on its context, like a store that can't be reached.
+type blockingBlobStore struct{}
+
+func (blockingBlobStore) Put(key string, data []byte) error { return errors.New("unreachable") }
+func (blockingBlobStore) Delete(key string) error           { return errors.New("unreachable") }
+
+func (blockingBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestInlineClaimCheckGivesUp(t *testing.T) {
+
+	con := &Consumer{blobStoreLock: &sync.RWMutex{}}
+	con.SetBlobStore(blockingBlobStore{})
+
+	msg := models.NewMessage(false, map[string]interface{}{models.ClaimCheckHeader: "BlobKey"}, nil, 1, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fetched := make(chan bool)
+	go func() { fetched <- con.inlineClaimCheck(ctx, msg) }()
+
+	cancel()
+	assert.False(t, <-fetched)
+}
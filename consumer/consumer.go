@@ -1,13 +1,16 @@
 package consumer
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prom3t3us/turbocookedrabbit/models"
 	"github.com/prom3t3us/turbocookedrabbit/pools"
+	"github.com/prom3t3us/turbocookedrabbit/utils"
 	"github.com/streadway/amqp"
 )
 
@@ -19,8 +22,8 @@ type Consumer struct {
 	QueueName            string
 	ConsumerName         string
 	errors               chan error
+	errorDispatch        *utils.Dispatcher
 	sleepOnErrorInterval time.Duration
-	sleepOnIdleInterval  time.Duration
 	messageGroup         *sync.WaitGroup
 	messages             chan *models.Message
 	consumeStop          chan bool
@@ -32,6 +35,37 @@ type Consumer struct {
 	args                 amqp.Table
 	qosCountOverride     int
 	conLock              *sync.Mutex
+	reassembler          *Reassembler
+	blobStore            utils.BlobStore
+	blobStoreLock        *sync.RWMutex
+	consumeDone          chan struct{}
+	handlerConfig        *models.HandlerConfig
+	outcomeMapper        func(error) models.Outcome
+	handlerLock          *sync.RWMutex
+	inFlight             int
+	inFlightCond         *sync.Cond
+	retryConfig          *models.RetryConfig
+	retryQueuesDeclared  bool
+	retryLock            *sync.Mutex
+	retryChannel         *retryChannel
+	ackBatchConfig       *models.AckBatchConfig
+	queueConfig          *models.Queue
+	prefetchTuner        *prefetchTuner
+	stats                *consumerStats
+	stream               *streamState
+	priority             int32
+	cancelOnHAFailover   bool
+	singleActive         bool
+	active               int32 // atomic, see Active
+	paused               bool
+	pauseSignal          chan struct{}
+}
+
+// Subscription is the consumer's basic.consume on its channel.
+type subscription struct {
+	consumerTag   string
+	deliveries    <-chan amqp.Delivery // nil while paused
+	cancellations <-chan string
 }
 
 // NewConsumerFromConfig creates a new Consumer to receive messages from a specific queuename.
@@ -52,7 +86,7 @@ func NewConsumerFromConfig(
 		return nil, errors.New("message and/or error buffer in config can't be 0")
 	}
 
-	return &Consumer{
+	con := &Consumer{
 		Config:               nil,
 		channelPool:          channelPool,
 		Enabled:              config.Enabled,
@@ -60,7 +94,6 @@ func NewConsumerFromConfig(
 		ConsumerName:         config.ConsumerName,
 		errors:               make(chan error, config.ErrorBuffer),
 		sleepOnErrorInterval: time.Duration(config.SleepOnErrorInterval) * time.Millisecond,
-		sleepOnIdleInterval:  time.Duration(config.SleepOnIdleInterval) * time.Millisecond,
 		messageGroup:         &sync.WaitGroup{},
 		messages:             make(chan *models.Message, config.MessageBuffer),
 		consumeStop:          make(chan bool, 1),
@@ -68,12 +101,81 @@ func NewConsumerFromConfig(
 		exclusive:            config.Exclusive,
 		noWait:               config.NoWait,
 		args:                 amqp.Table(config.Args),
+		priority:             config.Priority,
+		cancelOnHAFailover:   config.CancelOnHAFailover,
+		singleActive:         config.SingleActiveConsumer,
 		qosCountOverride:     config.QosCountOverride,
 		conLock:              &sync.Mutex{},
-	}, nil
+		blobStoreLock:        &sync.RWMutex{},
+		handlerConfig:        config.HandlerConfig,
+		retryConfig:          config.RetryConfig,
+		ackBatchConfig:       config.AckBatchConfig,
+		queueConfig:          config.QueueConfig,
+		retryLock:            &sync.Mutex{},
+		handlerLock:          &sync.RWMutex{},
+		inFlightCond:         sync.NewCond(&sync.Mutex{}),
+		stats:                &consumerStats{},
+		pauseSignal:          make(chan struct{}, 1),
+	}
+	con.errorDispatch = utils.NewErrorDispatcher(con.errors, config.ErrorOverflowPolicy)
+
+	if err := con.args.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid consumer args: %s", err)
+	}
+
+	if config.QueueConfig != nil {
+		if singleActive, ok := config.QueueConfig.Args["x-single-active-consumer"].(bool); ok && singleActive {
+			con.singleActive = true
+		}
+	}
+
+	if config.AdaptivePrefetch != nil {
+		if config.AdaptivePrefetch.MaxPrefetch < 1 {
+			return nil, errors.New("adaptive prefetch needs a MaxPrefetch")
+		}
+
+		con.prefetchTuner = newPrefetchTuner(config.AdaptivePrefetch, config.QosCountOverride)
+	}
+
+	if config.StreamConfig != nil {
+		switch {
+		case config.AutoAck:
+			return nil, errors.New("can't consume a stream with AutoAck")
+		case config.QosCountOverride < 1:
+			return nil, errors.New("stream consumption needs a QosCountOverride")
+		case config.AdaptivePrefetch != nil:
+			return nil, errors.New("adaptive prefetch can't be used on a stream, it needs a channel wide prefetch")
+		}
+
+		var err error
+		if con.stream, err = newStreamState(config.StreamConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.ReassemblyConfig != nil {
+		if config.ReassemblyConfig.Timeout == 0 {
+			return nil, errors.New("reassembly needs a timeout, a chunk group that never completes would hold the prefetch forever")
+		}
+
+		maxChunks := config.QosCountOverride // the smallest prefetch the consumer runs with, 0 is unlimited
+		if con.prefetchTuner != nil {
+			maxChunks = con.prefetchTuner.minPrefetch
+		} else if config.AutoAck {
+			maxChunks = 0
+		}
+
+		con.reassembler = NewReassembler(
+			time.Duration(config.ReassemblyConfig.Timeout)*time.Millisecond,
+			config.ReassemblyConfig.MaxBytes,
+			maxChunks)
+	}
+
+	return con, nil
 }
 
 // NewConsumer creates a new Consumer to receive messages from a specific queuename.
+// The sleepOnIdleInterval is no longer used, consumers block until a delivery arrives.
 func NewConsumer(
 	config *models.RabbitSeasoning,
 	channelPool *pools.ChannelPool,
@@ -101,14 +203,13 @@ func NewConsumer(
 		return nil, errors.New("message and/or error buffer can't be 0")
 	}
 
-	return &Consumer{
+	con := &Consumer{
 		Config:               config,
 		channelPool:          channelPool,
 		QueueName:            queuename,
 		ConsumerName:         consumerName,
 		errors:               make(chan error, errorBuffer),
 		sleepOnErrorInterval: time.Duration(sleepOnErrorInterval) * time.Millisecond,
-		sleepOnIdleInterval:  time.Duration(sleepOnIdleInterval) * time.Millisecond,
 		messageGroup:         &sync.WaitGroup{},
 		messages:             make(chan *models.Message, messageBuffer),
 		consumeStop:          make(chan bool, 1),
@@ -120,7 +221,16 @@ func NewConsumer(
 		args:                 amqp.Table(args),
 		qosCountOverride:     qosCountOverride,
 		conLock:              &sync.Mutex{},
-	}, nil
+		blobStoreLock:        &sync.RWMutex{},
+		handlerLock:          &sync.RWMutex{},
+		inFlightCond:         sync.NewCond(&sync.Mutex{}),
+		retryLock:            &sync.Mutex{},
+		stats:                &consumerStats{},
+		pauseSignal:          make(chan struct{}, 1),
+	}
+	con.errorDispatch = utils.NewErrorDispatcher(con.errors, "")
+
+	return con, nil
 }
 
 // Get gets a single message from any queue.
@@ -148,12 +258,7 @@ func (con *Consumer) Get(queueName string, autoAck bool) (*models.Message, error
 	}
 
 	if ok {
-		return models.NewMessage(
-			!autoAck,
-			amqpDelivery.Body,
-			amqpDelivery.DeliveryTag,
-			amqpDelivery.MessageId,
-			chanHost.Channel), nil
+		return models.NewMessageFromDelivery(!autoAck, &amqpDelivery, chanHost.Channel), nil
 	}
 	con.channelPool.ReturnChannel(chanHost, false)
 	return nil, nil
@@ -199,12 +304,7 @@ GetBatchLoop:
 			break GetBatchLoop
 		}
 
-		messages = append(messages, models.NewMessage(
-			!autoAck,
-			amqpDelivery.Body,
-			amqpDelivery.DeliveryTag,
-			amqpDelivery.MessageId,
-			chanHost.Channel))
+		messages = append(messages, models.NewMessageFromDelivery(!autoAck, &amqpDelivery, chanHost.Channel))
 	}
 
 	return messages, nil
@@ -224,7 +324,8 @@ func (con *Consumer) StartConsuming() error {
 		con.FlushErrors()
 		con.FlushStop()
 
-		go con.startConsuming()
+		con.consumeDone = make(chan struct{})
+		go con.startConsuming(con.consumeDone)
 		con.started = true
 	}
 
@@ -232,7 +333,8 @@ func (con *Consumer) StartConsuming() error {
 
 }
 
-func (con *Consumer) startConsuming() {
+func (con *Consumer) startConsuming(done chan struct{}) {
+	defer close(done)
 
 ConsumerOuterLoop:
 	for {
@@ -246,17 +348,32 @@ ConsumerOuterLoop:
 			break
 		}
 
-		deliveryChan, chanHost, err := con.getDeliveryChannel()
+		sub, chanHost, err := con.getDeliveryChannel()
 		if err != nil {
+			// Wait before retrying but still respond to a stop signal.
+			select {
+			case stop := <-con.consumeStop:
+				if stop {
+					break ConsumerOuterLoop
+				}
+			case <-time.After(con.sleepOnErrorInterval):
+			}
+
 			continue // retry
 		}
 
 		//ProcessDeliveries InnerLoop - Returns true when consumer stop is called.
-		if con.processDeliveries(deliveryChan, chanHost) {
+		if con.processDeliveries(sub, chanHost) {
 			break ConsumerOuterLoop
 		}
 	}
 
+	if con.stream != nil {
+		if err := con.SaveCheckpoint(); err != nil {
+			con.handleError(err)
+		}
+	}
+
 	con.conLock.Lock()
 	immediateStop := con.stopImmediate
 	con.conLock.Unlock()
@@ -265,20 +382,23 @@ ConsumerOuterLoop:
 		con.messageGroup.Wait() // wait for every message to be received to the internal queue
 	}
 
+	con.closeRetryChannel()
+
 	con.conLock.Lock()
 	con.started = false
 	con.stopImmediate = false
 	con.conLock.Unlock()
 }
 
-// GetDeliveryChannel attempts to get the amqp.Delivery chan and a viable ChannelHost from the ChannelPool.
-func (con *Consumer) getDeliveryChannel() (<-chan amqp.Delivery, *pools.ChannelHost, error) {
+// GetDeliveryChannel attempts to get the amqp.Delivery chan, as a subscription, and a viable ChannelHost from the
+// ChannelPool. The QueueConfig, when there is one, is declared before subscribing so a deleted queue comes back.
+func (con *Consumer) getDeliveryChannel() (*subscription, *pools.ChannelHost, error) {
 
 	// Get Channel
 	var chanHost *pools.ChannelHost
 	var err error
 
-	if con.autoAck {
+	if con.autoAck || con.holdsChannel() {
 		chanHost, err = con.channelPool.GetChannel()
 	} else {
 		chanHost, err = con.channelPool.GetAckableChannel()
@@ -290,67 +410,536 @@ func (con *Consumer) getDeliveryChannel() (<-chan amqp.Delivery, *pools.ChannelH
 	}
 
 	// Quality of Service channel overrides
-	if con.qosCountOverride > 0 {
+	if con.prefetchTuner != nil {
+		err := chanHost.Channel.Qos(con.prefetchTuner.current(), 0, true)
+		if err != nil {
+			con.handleErrorAndChannel(err, chanHost)
+			return nil, nil, err
+		}
+	} else if con.qosCountOverride > 0 {
 		err := chanHost.Channel.Qos(con.qosCountOverride, 0, false)
 		if err != nil {
+			con.handleErrorAndChannel(err, chanHost)
 			return nil, nil, err
 		}
 	}
 
-	// Start Consuming
-	deliveryChan, err := chanHost.Channel.Consume(con.QueueName, con.ConsumerName, con.autoAck, con.exclusive, false, con.noWait, nil)
+	if con.queueConfig != nil {
+		queueName := con.queueConfig.Name
+		if queueName == "" {
+			queueName = con.QueueName
+		}
+
+		_, err = chanHost.Channel.QueueDeclare(
+			queueName,
+			con.queueConfig.Durable,
+			con.queueConfig.AutoDelete,
+			con.queueConfig.Exclusive,
+			con.queueConfig.NoWait,
+			con.queueConfig.Args)
+		if err != nil {
+			con.handleErrorAndChannel(fmt.Errorf("can't declare the consumer's queue: %s", err), chanHost)
+			return nil, nil, err // Retry
+		}
+	}
+
+	sub, err := con.subscribe(chanHost)
 	if err != nil {
 		con.handleErrorAndChannel(err, chanHost)
 		return nil, nil, err // Retry
 	}
 
-	return deliveryChan, chanHost, nil
+	return sub, chanHost, nil
+}
+
+// Subscribe starts consuming the queue on the channel.
+func (con *Consumer) subscribe(chanHost *pools.ChannelHost) (*subscription, error) {
+
+	// Named by us, the server only tells which subscription it cancelled by its tag.
+	consumerTag := con.ConsumerName
+	if consumerTag == "" {
+		consumerTag = "ctag-" + utils.NewUUID()
+	}
+
+	sub := &subscription{
+		consumerTag:   consumerTag,
+		cancellations: chanHost.WatchCancellation(consumerTag),
+	}
+
+	if err := con.consume(chanHost, sub, nil); err != nil {
+		chanHost.StopWatchingCancellation(consumerTag)
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// Consume starts (or resumes) the subscription's basic.consume, keeping its consumerTag. A stream consumer starts
+// right after the last offset it processed or, resuming on the same channel (tracker), after the last one received.
+func (con *Consumer) consume(chanHost *pools.ChannelHost, sub *subscription, tracker *offsetTracker) error {
+
+	args := con.consumeArgs()
+	if con.stream != nil {
+		var offset interface{}
+		if received, ok := tracker.lastOffset(); ok {
+			offset = received + 1
+		} else {
+			var err error
+			if offset, err = con.stream.consumeOffset(con.checkpointKey()); err != nil {
+				return err
+			}
+		}
+
+		args["x-stream-offset"] = offset
+	}
+
+	// Start Consuming
+	deliveryChan, err := chanHost.Channel.Consume(con.QueueName, sub.consumerTag, con.autoAck, con.exclusive, false, con.noWait, args)
+	if err != nil {
+		return err
+	}
+
+	sub.deliveries = deliveryChan
+
+	// Only one consumer of a single active consumer queue gets deliveries, the server doesn't tell which.
+	con.setActive(!con.singleActive)
+	return nil
+}
+
+// ConsumeArgs gets the arguments of the consumer's basic.consume: its Args, priority and cancel-on-HA-failover.
+func (con *Consumer) consumeArgs() amqp.Table {
+
+	args := make(amqp.Table, len(con.args)+3)
+	for key, value := range con.args {
+		args[key] = value
+	}
+
+	if con.priority != 0 {
+		args["x-priority"] = con.priority
+	}
+
+	if con.cancelOnHAFailover {
+		args["x-cancel-on-ha-failover"] = true
+	}
+
+	return args
+}
+
+// Active lets you know if the consumer is subscribed and getting deliveries. The consumer of a single active consumer
+// queue (SingleActiveConsumer) only counts as active once it received a delivery since it subscribed, the server
+// doesn't say which consumer is the active one so a consumer waiting on an empty queue reports false.
+func (con *Consumer) Active() bool {
+	return atomic.LoadInt32(&con.active) == 1
+}
+
+func (con *Consumer) setActive(active bool) {
+	if active {
+		atomic.StoreInt32(&con.active, 1)
+	} else {
+		atomic.StoreInt32(&con.active, 0)
+	}
 }
 
 // ProcessDeliveries is the inner loop for processing the deliveries and returns true to break outer loop.
-func (con *Consumer) processDeliveries(deliveryChan <-chan amqp.Delivery, chanHost *pools.ChannelHost) bool {
+// Blocks until a delivery, a channel closure, or a stop signal arrives.
+func (con *Consumer) processDeliveries(sub *subscription, chanHost *pools.ChannelHost) bool {
+
+	defer con.setActive(false) // the subscription ends with the inner loop
+	defer chanHost.StopWatchingCancellation(sub.consumerTag)
+
+	var sweep <-chan time.Time // nil (never fires) unless incomplete chunk groups can time out
+	if con.reassembler != nil && con.reassembler.timeout > 0 {
+		ticker := time.NewTicker(con.reassembler.timeout / 2)
+		defer ticker.Stop()
+
+		sweep = ticker.C
+	}
+
+	var acknowledger amqp.Acknowledger = chanHost.Channel
+	var tracker *offsetTracker
+	var checkpoint <-chan time.Time // nil (never fires) unless consuming a stream
+	if con.stream != nil {
+		tracker = newOffsetTracker(acknowledger, con.stream)
+		acknowledger = tracker
+
+		ticker := time.NewTicker(con.stream.interval)
+		defer ticker.Stop()
+
+		checkpoint = ticker.C
+	}
+
+	var batch *batchAcknowledger
+	var flush <-chan time.Time // nil (never fires) unless acks are batched
+	if con.batchingAcks() {
+		size, interval := 100, 100*time.Millisecond
+		if con.ackBatchConfig.Size > 0 {
+			size = int(con.ackBatchConfig.Size)
+		}
+		if con.ackBatchConfig.Interval > 0 {
+			interval = time.Duration(con.ackBatchConfig.Interval) * time.Millisecond
+		}
+
+		batch = newBatchAcknowledger(acknowledger, size)
+		acknowledger = batch
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		flush = ticker.C
+	}
+
+	var tune <-chan time.Time // nil (never fires) unless the prefetch is adaptive
+	if con.prefetchTuner != nil {
+		ticker := time.NewTicker(con.prefetchTuner.interval)
+		defer ticker.Stop()
+
+		tune = ticker.C
+	}
+
+	fetching, stopFetching := context.WithCancel(context.Background()) // claim-checked Bodies still being fetched
+	defer stopFetching()
+
+	var draining <-chan amqp.Delivery // deliveries of a paused subscription still arriving
+	if con.Paused() {
+		draining = con.pauseSubscription(sub, chanHost)
+	}
 
-ProcessDeliveriesInnerLoop:
 	for {
-		// Listen for channel closure (close errors).
-		// Highest priority so separated to it's own select.
 		select {
-		case errorMessage := <-chanHost.CloseErrors():
+		case errorMessage := <-chanHost.CloseErrors(): // listen for channel closure (close errors).
 			if errorMessage != nil {
+				con.discardAcknowledger(batch)
 				con.handleErrorAndChannel(fmt.Errorf("consumer's current channel closed\r\n[reason: %s]\r\n[code: %d]", errorMessage.Reason, errorMessage.Code), chanHost)
-				break ProcessDeliveriesInnerLoop
+				return false
 			}
-		default:
-			break
-		}
 
-		// Convert amqp.Delivery into our internal struct for later use.
-		select {
-		case delivery := <-deliveryChan: // all buffered deliveries are wipe on a channel close error
-			con.messageGroup.Add(1)
-			con.convertDelivery(chanHost.Channel, &delivery, !con.autoAck)
-		default:
-			time.Sleep(con.sleepOnIdleInterval)
-			break
-		}
+		case consumerTag := <-sub.cancellations: // the queue was deleted or moved (HA / quorum leader change)
+			con.handleError(fmt.Errorf("consumer %s was cancelled by the server", consumerTag))
+			con.unsubscribe(fetching, sub, draining, chanHost, true)
+			stopFetching()
+			return con.resubscribe(chanHost, batch)
+
+		case delivery, ok := <-sub.deliveries: // all buffered deliveries are wipe on a channel close error
+			if !ok {
+				select {
+				case consumerTag := <-sub.cancellations: // the deliveries close right after the cancellation arrives
+					con.handleError(fmt.Errorf("consumer %s was cancelled by the server", consumerTag))
+					con.unsubscribe(fetching, sub, draining, chanHost, true)
+					stopFetching()
+					return con.resubscribe(chanHost, batch)
+				default:
+				}
+
+				con.discardAcknowledger(batch)
+				con.handleErrorAndChannel(con.deliveriesClosedError(chanHost), chanHost)
+				return false
+			}
 
-		// Detect if we should stop.
-		select {
-		case stop := <-con.consumeStop:
+			con.receive(fetching, acknowledger, tracker, batch, &delivery)
+
+		case delivery, ok := <-draining:
+			if !ok {
+				draining = nil
+				if !con.Paused() && !con.resumeSubscription(sub, chanHost, tracker, batch) {
+					return false
+				}
+
+				continue
+			}
+
+			con.receive(fetching, acknowledger, tracker, batch, &delivery)
+
+		case <-con.pauseSignal:
+			switch paused := con.Paused(); {
+			case paused && sub.deliveries != nil:
+				draining = con.pauseSubscription(sub, chanHost)
+
+			case !paused && sub.deliveries == nil && draining == nil: // otherwise resumed once drained
+				if !con.resumeSubscription(sub, chanHost, tracker, batch) {
+					return false
+				}
+			}
+
+		case now := <-sweep:
+			con.rejectExpiredChunks(now)
+
+		case <-tune:
+			con.tunePrefetch(func(prefetch int) error { return chanHost.Channel.Qos(prefetch, 0, true) })
+
+		case <-checkpoint:
+			if err := con.SaveCheckpoint(); err != nil {
+				con.handleError(err)
+			}
+
+		case <-flush:
+			if err := batch.Flush(true); err != nil {
+				con.handleError(fmt.Errorf("can't flush acknowledgements: %s", err))
+			}
+
+		case stop := <-con.consumeStop: // detect if we should stop.
 			if stop {
-				con.channelPool.ReturnChannel(chanHost, false)
+				con.unsubscribe(fetching, sub, draining, chanHost, false)
+				stopFetching()
+				con.releaseChannel(chanHost, batch)
 				return true
 			}
-		default:
-			break
 		}
 	}
+}
+
+// Receive converts a delivery into a message for the buffer.
+func (con *Consumer) receive(
+	ctx context.Context,
+	acknowledger amqp.Acknowledger,
+	tracker *offsetTracker,
+	batch *batchAcknowledger,
+	delivery *amqp.Delivery) {
+
+	atomic.AddUint64(&con.stats.received, 1)
+	if con.singleActive {
+		con.setActive(true)
+	}
+
+	if tracker != nil {
+		tracker.track(delivery)
+	}
+
+	if batch != nil {
+		batch.track(delivery.DeliveryTag)
+	}
+
+	// Convert amqp.Delivery into our internal struct for later use.
+	con.messageGroup.Add(1)
+	con.convertDelivery(ctx, acknowledger, delivery, !con.autoAck)
+}
+
+// Unsubscribe ends the subscription of a channel going back to the pool open, where it would keep consuming for
+// nobody: basic.cancel, unless the server cancelled it or it's paused, then the deliveries sent before are received.
+// A held channel is closed instead, its deliveries are requeued by the server.
+func (con *Consumer) unsubscribe(
+	ctx context.Context,
+	sub *subscription,
+	draining <-chan amqp.Delivery,
+	chanHost *pools.ChannelHost,
+	cancelled bool) {
+
+	if con.holdsChannel() {
+		return
+	}
+
+	deliveries := sub.deliveries
+	if deliveries == nil {
+		deliveries = draining // paused, already cancelled
+	} else if !cancelled {
+		if err := chanHost.Channel.Cancel(sub.consumerTag, false); err != nil {
+			con.handleError(fmt.Errorf("can't cancel the subscription: %s", err)) // the channel is closing
+		}
+	}
+
+	if deliveries == nil {
+		return
+	}
+
+	for delivery := range deliveries { // closed once the cancel is done
+		delivery := delivery
+		con.receive(ctx, chanHost.Channel, nil, nil, &delivery)
+	}
+}
+
+// PauseSubscription cancels the subscription (basic.cancel) keeping the channel and the consumerTag. Returns the
+// deliveries the server sent before the cancel, they still arrive until the channel returned is closed.
+func (con *Consumer) pauseSubscription(sub *subscription, chanHost *pools.ChannelHost) <-chan amqp.Delivery {
+
+	draining := sub.deliveries
+	sub.deliveries = nil
+	con.setActive(false)
+
+	if err := chanHost.Channel.Cancel(sub.consumerTag, false); err != nil {
+		con.handleError(fmt.Errorf("can't pause consuming: %s", err)) // the channel is closing, its close error follows
+	}
+
+	return draining
+}
+
+// ResumeSubscription consumes again after a pause, false when the channel failed and was released.
+func (con *Consumer) resumeSubscription(
+	sub *subscription,
+	chanHost *pools.ChannelHost,
+	tracker *offsetTracker,
+	batch *batchAcknowledger) bool {
+
+	if err := con.consume(chanHost, sub, tracker); err != nil {
+		con.discardAcknowledger(batch)
+		con.handleErrorAndChannel(fmt.Errorf("can't resume consuming: %s", err), chanHost)
+		return false
+	}
+
+	return true
+}
+
+// Pause stops new deliveries, cancelling the subscription (basic.cancel) but keeping the consumer's channel, messages
+// already received are still buffered and handled. A stopped consumer starts paused.
+func (con *Consumer) Pause() {
+	con.setPaused(true)
+}
+
+// Resume subscribes again, on the same channel, after a Pause.
+func (con *Consumer) Resume() {
+	con.setPaused(false)
+}
+
+// Paused lets you know if the consumer is paused.
+func (con *Consumer) Paused() bool {
+	con.conLock.Lock()
+	defer con.conLock.Unlock()
+
+	return con.paused
+}
+
+func (con *Consumer) setPaused(paused bool) {
+	con.conLock.Lock()
+	defer con.conLock.Unlock()
+
+	con.paused = paused
+
+	select {
+	case con.pauseSignal <- struct{}{}:
+	default: // already signaled, the consumer reads the state when it gets to it
+	}
+}
+
+// Resubscribe releases the channel of a cancelled subscription, which is still open, and waits the
+// SleepOnErrorInterval before the consumer subscribes again. Returns true when the consumer is stopped meanwhile.
+func (con *Consumer) resubscribe(chanHost *pools.ChannelHost, batch *batchAcknowledger) bool {
+
+	con.releaseChannel(chanHost, batch)
+
+	select {
+	case stop := <-con.consumeStop:
+		if stop {
+			return true
+		}
+	case <-time.After(con.sleepOnErrorInterval):
+	}
 
 	return false
 }
 
+// ReleaseChannel returns the subscription's channel, unsubscribed, to the pool once the running handlers settled their
+// messages. A held channel is closed instead, ending its subscription and channel wide prefetch, the messages still
+// buffered are requeued first as nothing can settle them afterwards.
+func (con *Consumer) releaseChannel(chanHost *pools.ChannelHost, batch *batchAcknowledger) {
+
+	if con.holdsChannel() {
+		con.requeueBuffered()
+	}
+
+	con.waitInFlight() // running handlers still settle their messages on this channel
+	con.releaseAcknowledger(batch)
+
+	if con.holdsChannel() {
+		con.channelPool.FlagChannel(chanHost.ChannelID)
+		_ = chanHost.Channel.Close()
+	}
+
+	con.channelPool.ReturnChannel(chanHost, false)
+}
+
+// HoldsChannel lets you know if the consumer needs a channel nobody else consumes on: for multiple acks, a channel wide
+// prefetch or to follow a stream's delivery tags.
+func (con *Consumer) holdsChannel() bool {
+	return con.batchingAcks() || con.prefetchTuner != nil || con.stream != nil
+}
+
+// BatchingAcks lets you know if the consumer coalesces its acks, see AckBatchConfig.
+func (con *Consumer) batchingAcks() bool {
+	return con.ackBatchConfig != nil && !con.autoAck
+}
+
+// ReleaseAcknowledger flushes the acks of a batching consumer before its channel is released.
+func (con *Consumer) releaseAcknowledger(batch *batchAcknowledger) {
+	if batch == nil {
+		return
+	}
+
+	if err := batch.Release(); err != nil {
+		con.handleError(fmt.Errorf("can't flush acknowledgements: %s", err))
+	}
+}
+
+// RequeueBuffered nacks (requeue) the ackable messages left in the buffer, and the ones still making it there, before
+// the channel they were received on is closed. Handlers running meanwhile may still take some, they are waited on.
+func (con *Consumer) requeueBuffered() {
+
+	received := make(chan struct{})
+	go func() {
+		con.messageGroup.Wait() // no delivery is received meanwhile, the loop adding to it is the one stopping
+		close(received)
+	}()
+
+	requeue := func(msg *models.Message) {
+		if !msg.IsAckable {
+			return
+		}
+
+		if err := msg.Nack(true); err != nil {
+			con.handleError(fmt.Errorf("can't requeue buffered message while stopping: %s", err))
+		}
+	}
+
+	for {
+		select {
+		case msg := <-con.messages:
+			requeue(msg)
+		case <-received:
+			con.drainMessages(requeue)
+			return
+		}
+	}
+}
+
+// DiscardAcknowledger drops the acks of a batching consumer whose channel closed, its messages are redelivered.
+func (con *Consumer) discardAcknowledger(batch *batchAcknowledger) {
+	if batch != nil {
+		batch.Discard()
+	}
+}
+
+// RejectExpiredChunks rejects the chunks of chunk groups that didn't complete in time.
+func (con *Consumer) rejectExpiredChunks(now time.Time) {
+
+	expired := con.reassembler.Sweep(now)
+	if len(expired) == 0 {
+		return
+	}
+
+	for _, chunk := range expired {
+		if chunk.IsAckable {
+			_ = chunk.Reject(false)
+		}
+	}
+
+	con.handleError(fmt.Errorf("rejected %d chunks of chunk groups that didn't complete within %s", len(expired), con.reassembler.timeout))
+}
+
+// DeliveriesClosedError describes why the delivery channel closed, the channel's close error (if any) arrives first.
+func (con *Consumer) deliveriesClosedError(chanHost *pools.ChannelHost) error {
+	select {
+	case errorMessage := <-chanHost.CloseErrors():
+		if errorMessage != nil {
+			return fmt.Errorf("consumer's current channel closed\r\n[reason: %s]\r\n[code: %d]", errorMessage.Reason, errorMessage.Code)
+		}
+	default:
+	}
+
+	return errors.New("consumer's delivery channel closed")
+}
+
 // StopConsuming allows you to signal stop to the consumer.
 // Will stop on the consumer channelclose or responding to signal after getting all remaining deviveries.
+// The channel is only released once the handlers running in Handle return. A consumer holding its channel (batching
+// acks, adaptive prefetch or stream) requeues the messages still buffered, flushes its acks and closes its channel:
+// Handle and Messages don't get the buffered messages after a stop.
 // FlushMessages empties the internal buffer of messages received by queue. Ackable messages are still in
 // RabbitMQ queue, while noAck messages will unfortunately be lost. Use wisely.
 func (con *Consumer) StopConsuming(immediate bool, flushMessages bool) error {
@@ -383,7 +972,7 @@ func (con *Consumer) handleErrorAndChannel(err error, chanHost *pools.ChannelHos
 }
 
 func (con *Consumer) handleError(err error) {
-	go func() { con.errors <- err }()
+	con.errorDispatch.Dispatch(err)
 }
 
 // Errors yields all the internal errs for consuming messages.
@@ -391,17 +980,43 @@ func (con *Consumer) Errors() <-chan error {
 	return con.errors
 }
 
-func (con *Consumer) convertDelivery(amqpChan *amqp.Channel, delivery *amqp.Delivery, isAckable bool) {
-	msg := models.NewMessage(
-		isAckable,
-		delivery.Body,
-		delivery.DeliveryTag,
-		delivery.MessageId,
-		amqpChan)
+// ErrorDispatcher gets the Dispatcher behind Errors, to handle the consumer's errors (like ones from the handlers of
+// Handle) with SetErrorHandler instead.
+func (con *Consumer) ErrorDispatcher() *utils.Dispatcher {
+	return con.errorDispatch
+}
+
+// ConvertDelivery turns the delivery into a Message and puts it in the buffer. Fetching a claim-checked Body and
+// reassembling chunks happen on their own goroutine so a slow BlobStore doesn't hold up the delivery loop, ctx is
+// done once the subscription ends.
+func (con *Consumer) convertDelivery(ctx context.Context, acknowledger amqp.Acknowledger, delivery *amqp.Delivery, isAckable bool) {
+	msg := models.NewMessageFromDelivery(isAckable, delivery, acknowledger)
 
 	go func() {
 		defer con.messageGroup.Done() // finished after getting the message in the channel
 
+		if !con.inlineClaimCheck(ctx, msg) {
+			return
+		}
+
+		if con.reassembler != nil {
+			chunk := msg
+
+			var err error
+			if msg, err = con.reassembler.Add(chunk); err != nil {
+				if chunk.IsAckable {
+					_ = chunk.Reject(false)
+				}
+
+				con.handleError(err)
+				return
+			}
+
+			if msg == nil { // waiting on the rest of its chunk group
+				return
+			}
+		}
+
 		con.messages <- msg
 	}()
 }
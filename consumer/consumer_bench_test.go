@@ -0,0 +1,49 @@
+package consumer_test
+
+import (
+	"testing"
+
+	"github.com/prom3t3us/turbocookedrabbit/consumer"
+	"github.com/prom3t3us/turbocookedrabbit/publisher"
+	"github.com/prom3t3us/turbocookedrabbit/utils"
+)
+
+// BenchmarkConsumeLatency measures the time from publishing a single letter to receiving it from Messages().
+// BenchmarkDeliveryHandoff compares the former SleepOnIdleInterval loop with blocking on deliveries without RabbitMQ.
+func BenchmarkConsumeLatency(b *testing.B) {
+	b.ReportAllocs()
+	requireBroker(b)
+
+	consumerConfig, ok := Seasoning.ConsumerConfigs["TurboCookedRabbitConsumer-AutoAck"]
+	if !ok {
+		b.Fatal("consumer config not found")
+	}
+
+	con, err := consumer.NewConsumerFromConfig(consumerConfig, ChannelPool)
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	pub, err := publisher.NewPublisher(Seasoning, ChannelPool, ConnectionPool)
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	if err = con.StartConsuming(); err != nil {
+		b.Fatal(err.Error())
+	}
+
+	letter := utils.CreateMockLetter(1, "", consumerConfig.QueueName, nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pub.Publish(letter)
+		<-pub.Notifications()
+		<-con.Messages()
+	}
+	b.StopTimer()
+
+	if err = con.StopConsuming(false, true); err != nil {
+		b.Error(err.Error())
+	}
+}
@@ -3,7 +3,9 @@ package consumer_test
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 	"github.com/prom3t3us/turbocookedrabbit/models"
 	"github.com/prom3t3us/turbocookedrabbit/pools"
 	"github.com/prom3t3us/turbocookedrabbit/publisher"
+	"github.com/prom3t3us/turbocookedrabbit/topology"
 	"github.com/prom3t3us/turbocookedrabbit/utils"
 )
 
@@ -31,18 +34,27 @@ func TestMain(m *testing.M) {
 	ConnectionPool, err = pools.NewConnectionPool(Seasoning.PoolConfig, true)
 	if err != nil {
 		fmt.Print(err.Error())
-		return
+		os.Exit(m.Run()) // tests needing RabbitMQ skip themselves
 	}
 	ChannelPool, err = pools.NewChannelPool(Seasoning.PoolConfig, ConnectionPool, true)
 	if err != nil {
 		fmt.Print(err.Error())
-		return
+		os.Exit(m.Run())
 	}
 
 	os.Exit(m.Run())
 }
 
+// RequireBroker skips a test needing RabbitMQ when TestMain couldn't connect to it.
+func requireBroker(tb testing.TB) {
+	if ChannelPool == nil {
+		tb.Skip("RabbitMQ isn't reachable")
+	}
+}
+
 func TestCreateConsumer(t *testing.T) {
+	requireBroker(t)
+
 	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
 	assert.NoError(t, err)
 
@@ -75,6 +87,8 @@ func TestCreateConsumer(t *testing.T) {
 }
 
 func TestCreateConsumerAndGet(t *testing.T) {
+	requireBroker(t)
+
 	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
 	assert.NoError(t, err)
 
@@ -98,6 +112,8 @@ func TestCreateConsumerAndGet(t *testing.T) {
 }
 
 func TestCreateConsumerAndGetBatch(t *testing.T) {
+	requireBroker(t)
+
 	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
 	assert.NoError(t, err)
 
@@ -121,6 +137,8 @@ func TestCreateConsumerAndGetBatch(t *testing.T) {
 }
 
 func TestCreateConsumerAndPublisher(t *testing.T) {
+	requireBroker(t)
+
 	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
 	assert.NoError(t, err)
 
@@ -139,6 +157,8 @@ func TestCreateConsumerAndPublisher(t *testing.T) {
 }
 
 func TestCreateConsumerAndUncleanShutdown(t *testing.T) {
+	requireBroker(t)
+
 	defer leaktest.Check(t)() // Fail on leaked goroutines.
 
 	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
@@ -174,6 +194,8 @@ ErrorLoop:
 }
 
 func TestPublishAndConsume(t *testing.T) {
+	requireBroker(t)
+
 	defer leaktest.Check(t)() // Fail on leaked goroutines.
 
 	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
@@ -246,6 +268,7 @@ ErrorLoop:
 }
 
 func TestPublishAndConsumeMany(t *testing.T) {
+	requireBroker(t)
 
 	t.Logf("%s: Benchmark started...", time.Now())
 
@@ -372,3 +395,665 @@ ConsumeMessages:
 	t.Logf("%s: Messages Failed to Publish: %d\r\n", time.Now(), messagesFailedToPublish)
 	t.Logf("%s: Messages Received: %d\r\n", time.Now(), messagesReceived)
 }
+
+func TestPublishAndConsumeChunkedLetter(t *testing.T) {
+	requireBroker(t)
+
+	publisherConfig := *Seasoning.PublisherConfig
+	publisherConfig.ChunkSize = 1000
+	config := *Seasoning
+	config.PublisherConfig = &publisherConfig
+
+	channelPool, err := pools.NewChannelPool(config.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	publisher, err := publisher.NewPublisher(&config, channelPool, nil)
+	assert.NoError(t, err)
+
+	consumerConfig := *Seasoning.ConsumerConfigs["TurboCookedRabbitConsumer-Ackable"]
+	consumerConfig.ReassemblyConfig = &models.ReassemblyConfig{Timeout: 5000}
+
+	consumer, err := consumer.NewConsumerFromConfig(&consumerConfig, channelPool)
+	assert.NoError(t, err)
+
+	letter := utils.CreateMockLetter(1, "", "ConsumerTestQueue", utils.RandomBytes(4500))
+	publisher.Publish(letter)
+	assert.True(t, (<-publisher.Notifications()).Success)
+
+	err = consumer.StartConsuming()
+	assert.NoError(t, err)
+
+	select {
+	case message := <-consumer.Messages():
+		assert.Equal(t, letter.Body, message.Body)
+		assert.Nil(t, message.Headers[models.ChunkGroupHeader])
+		assert.NoError(t, message.Acknowledge())
+	case err := <-consumer.Errors():
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "timed out waiting on the reassembled message")
+	}
+
+	assert.NoError(t, consumer.StopConsuming(false, true))
+	channelPool.Shutdown()
+}
+
+func TestPublishAndConsumeClaimCheckedLetter(t *testing.T) {
+	requireBroker(t)
+
+	directory, err := ioutil.TempDir("", "TurboCookedRabbitBlobs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(directory)
+
+	blobStore, err := utils.NewFileBlobStore(directory)
+	assert.NoError(t, err)
+
+	publisherConfig := *Seasoning.PublisherConfig
+	publisherConfig.ClaimCheckThreshold = 1000
+	config := *Seasoning
+	config.PublisherConfig = &publisherConfig
+
+	channelPool, err := pools.NewChannelPool(config.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	publisher, err := publisher.NewPublisher(&config, channelPool, nil)
+	assert.NoError(t, err)
+	publisher.SetBlobStore(blobStore)
+
+	consumer, err := consumer.NewConsumerFromConfig(Seasoning.ConsumerConfigs["TurboCookedRabbitConsumer-Ackable"], channelPool)
+	assert.NoError(t, err)
+	consumer.SetBlobStore(blobStore)
+
+	letter := utils.CreateMockLetter(1, "", "ConsumerTestQueue", utils.RandomBytes(4500))
+	publisher.Publish(letter)
+	assert.True(t, (<-publisher.Notifications()).Success)
+
+	err = consumer.StartConsuming()
+	assert.NoError(t, err)
+
+	var key string
+	select {
+	case message := <-consumer.Messages():
+		var ok bool
+		key, ok = message.ClaimCheckKey()
+		assert.True(t, ok)
+		assert.NotEqual(t, letter.MessageID, key)
+		assert.Equal(t, letter.Body, message.Body)
+		assert.NoError(t, message.Acknowledge())
+		assert.NoError(t, consumer.CleanupClaimCheck(message))
+	case err := <-consumer.Errors():
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "timed out waiting on the claim-checked message")
+	}
+
+	_, err = blobStore.Get(context.Background(), key)
+	assert.Error(t, err)
+
+	assert.NoError(t, consumer.StopConsuming(false, true))
+	channelPool.Shutdown()
+}
+
+func TestConsumerHandle(t *testing.T) {
+	requireBroker(t)
+
+	consumerConfig := *Seasoning.ConsumerConfigs["TurboCookedRabbitConsumer-Ackable"]
+	consumerConfig.HandlerConfig = &models.HandlerConfig{Timeout: 500}
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	publisher, err := publisher.NewPublisher(Seasoning, channelPool, nil)
+	assert.NoError(t, err)
+
+	con, err := consumer.NewConsumerFromConfig(&consumerConfig, channelPool)
+	assert.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		publisher.Publish(utils.CreateMockRandomLetter("ConsumerTestQueue"))
+		assert.True(t, (<-publisher.Notifications()).Success)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	handled := 0
+	retried := false
+	err = con.Handle(ctx, func(ctx context.Context, msg *models.Message) error {
+		handled++
+		switch handled {
+		case 1:
+			return nil // acked
+		case 2:
+			retried = true
+			return consumer.Retryable(fmt.Errorf("try again")) // requeued, handled again later
+		case 3:
+			panic("recovered and rejected")
+		case 4:
+			<-ctx.Done() // timed out and requeued
+			return ctx.Err()
+		case 5:
+			return consumer.Permanent(fmt.Errorf("rejected"))
+		}
+
+		if handled == 6 {
+			cancel()
+		}
+
+		return nil
+	})
+
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, retried)
+	assert.True(t, handled >= 6)
+
+	channelPool.Shutdown()
+}
+
+func TestConsumerHandleWorkers(t *testing.T) {
+	requireBroker(t)
+
+	consumerConfig := *Seasoning.ConsumerConfigs["TurboCookedRabbitConsumer-Ackable"]
+	consumerConfig.QosCountOverride = 4
+	consumerConfig.HandlerConfig = &models.HandlerConfig{WorkerCount: 10} // capped to 4
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	publisher, err := publisher.NewPublisher(Seasoning, channelPool, nil)
+	assert.NoError(t, err)
+
+	con, err := consumer.NewConsumerFromConfig(&consumerConfig, channelPool)
+	assert.NoError(t, err)
+
+	for i := 0; i < 40; i++ {
+		publisher.Publish(utils.CreateMockRandomLetter("ConsumerTestQueue"))
+		assert.True(t, (<-publisher.Notifications()).Success)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var running, maxRunning, handled int32
+	err = con.Handle(ctx, func(ctx context.Context, msg *models.Message) error {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+				break
+			}
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		if atomic.AddInt32(&handled, 1) == 40 {
+			go func() { assert.NoError(t, con.StopConsuming(false, false)) }()
+		}
+
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&running)) // StopConsuming waited on the running handlers
+	assert.True(t, atomic.LoadInt32(&maxRunning) > 1)
+	assert.True(t, atomic.LoadInt32(&maxRunning) <= 4)
+
+	channelPool.Shutdown()
+}
+
+func TestConsumerRetryTiersAndParkingLot(t *testing.T) {
+	requireBroker(t)
+
+	consumerConfig := *Seasoning.ConsumerConfigs["TurboCookedRabbitConsumer-Ackable"]
+	consumerConfig.QueueName = "ConsumerRetryTestQueue"
+	consumerConfig.RetryConfig = &models.RetryConfig{Delays: []uint32{100, 200}}
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	topologer, err := topology.NewTopologer(channelPool)
+	assert.NoError(t, err)
+
+	err = topologer.CreateQueue("ConsumerRetryTestQueue", false, true, false, false, false, nil)
+	assert.NoError(t, err)
+
+	con, err := consumer.NewConsumerFromConfig(&consumerConfig, channelPool)
+	assert.NoError(t, err)
+	assert.NoError(t, con.DeclareRetryQueues())
+
+	_, err = topologer.PurgeQueues([]string{"ConsumerRetryTestQueue", con.ParkingLotQueue()}, false)
+	assert.NoError(t, err)
+
+	publisher, err := publisher.NewPublisher(Seasoning, channelPool, nil)
+	assert.NoError(t, err)
+
+	publisher.Publish(utils.CreateMockRandomLetter("ConsumerRetryTestQueue"))
+	assert.True(t, (<-publisher.Notifications()).Success)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	attempts := 0
+	err = con.Handle(ctx, func(ctx context.Context, msg *models.Message) error {
+		attempts++
+		if attempts == 3 {
+			go func() { assert.NoError(t, con.StopConsuming(false, false)) }()
+		}
+
+		return consumer.Retryable(fmt.Errorf("still failing"))
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts) // first delivery and both retries
+
+	// Parked after the last retry.
+	time.Sleep(100 * time.Millisecond)
+	message, err := con.Get(con.ParkingLotQueue(), true)
+	assert.NoError(t, err)
+	if assert.NotNil(t, message) {
+		assert.EqualValues(t, 3, message.Headers[models.RetryAttemptHeader])
+	}
+
+	channelPool.Shutdown()
+}
+
+func TestGetMessageMetadata(t *testing.T) {
+	requireBroker(t)
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	publisher, err := publisher.NewPublisher(Seasoning, channelPool, nil)
+	assert.NoError(t, err)
+
+	con, err := consumer.NewConsumerFromConfig(Seasoning.ConsumerConfigs["TurboCookedRabbitConsumer-Ackable"], channelPool)
+	assert.NoError(t, err)
+
+	letter := utils.CreateMockRandomLetter("ConsumerTestQueue")
+	letter.Envelope.Headers = map[string]interface{}{"x-test": "metadata"}
+	publisher.Publish(letter)
+	assert.True(t, (<-publisher.Notifications()).Success)
+
+	message, err := con.Get("ConsumerTestQueue", false)
+	assert.NoError(t, err)
+	if assert.NotNil(t, message) {
+		assert.Equal(t, letter.MessageID, message.MessageID)
+		assert.Equal(t, letter.Envelope.Exchange, message.Exchange)
+		assert.Equal(t, letter.Envelope.RoutingKey, message.RoutingKey)
+		assert.Equal(t, letter.Envelope.ContentType, message.ContentType)
+		assert.Equal(t, "metadata", message.Headers["x-test"])
+		assert.False(t, message.Redelivered)
+		assert.NoError(t, message.Acknowledge())
+	}
+
+	channelPool.Shutdown()
+}
+
+func TestConsumerBatchedAcks(t *testing.T) {
+	requireBroker(t)
+
+	consumerConfig := *Seasoning.ConsumerConfigs["TurboCookedRabbitConsumer-Ackable"]
+	consumerConfig.QueueName = "ConsumerBatchedAcksTestQueue"
+	consumerConfig.HandlerConfig = &models.HandlerConfig{WorkerCount: 4}
+	consumerConfig.AckBatchConfig = &models.AckBatchConfig{Size: 10, Interval: 50}
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	topologer, err := topology.NewTopologer(channelPool)
+	assert.NoError(t, err)
+
+	err = topologer.CreateQueue("ConsumerBatchedAcksTestQueue", false, true, false, false, false, nil)
+	assert.NoError(t, err)
+
+	_, err = topologer.PurgeQueue("ConsumerBatchedAcksTestQueue", false)
+	assert.NoError(t, err)
+
+	publisher, err := publisher.NewPublisher(Seasoning, channelPool, nil)
+	assert.NoError(t, err)
+
+	for i := 0; i < 95; i++ {
+		publisher.Publish(utils.CreateMockRandomLetter("ConsumerBatchedAcksTestQueue"))
+		assert.True(t, (<-publisher.Notifications()).Success)
+	}
+
+	con, err := consumer.NewConsumerFromConfig(&consumerConfig, channelPool)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var handled int32
+	err = con.Handle(ctx, func(ctx context.Context, msg *models.Message) error {
+		if atomic.AddInt32(&handled, 1) == 95 {
+			go func() { assert.NoError(t, con.StopConsuming(false, false)) }()
+		}
+
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// Every ack, including the last partial batch, was flushed before the channel was released.
+	chanHost, err := channelPool.GetChannel()
+	assert.NoError(t, err)
+
+	queue, err := chanHost.Channel.QueueInspect("ConsumerBatchedAcksTestQueue")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, queue.Messages)
+
+	channelPool.ReturnChannel(chanHost, false)
+	channelPool.Shutdown()
+}
+
+func TestConsumerResubscribesAfterCancellation(t *testing.T) {
+	requireBroker(t)
+
+	consumerConfig := *Seasoning.ConsumerConfigs["TurboCookedRabbitConsumer-Ackable"]
+	consumerConfig.QueueName = "ConsumerCancelTestQueue"
+	consumerConfig.QueueConfig = &models.Queue{Durable: true}
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	topologer, err := topology.NewTopologer(channelPool)
+	assert.NoError(t, err)
+
+	con, err := consumer.NewConsumerFromConfig(&consumerConfig, channelPool)
+	assert.NoError(t, err)
+	assert.NoError(t, con.StartConsuming())
+
+	time.Sleep(200 * time.Millisecond)
+
+	// Deleting the queue cancels the subscription, the consumer declares the queue again and resubscribes.
+	_, err = topologer.QueueDelete("ConsumerCancelTestQueue", false, false, false)
+	assert.NoError(t, err)
+
+	select {
+	case err := <-con.Errors():
+		assert.Contains(t, err.Error(), "cancelled by the server")
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "timed out waiting on the cancellation")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	publisher, err := publisher.NewPublisher(Seasoning, channelPool, nil)
+	assert.NoError(t, err)
+
+	publisher.Publish(utils.CreateMockRandomLetter("ConsumerCancelTestQueue"))
+	assert.True(t, (<-publisher.Notifications()).Success)
+
+	select {
+	case message := <-con.Messages():
+		assert.NoError(t, message.Acknowledge())
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "timed out waiting on a message after resubscribing")
+	}
+
+	assert.NoError(t, con.StopConsuming(false, true))
+	channelPool.Shutdown()
+}
+
+func TestConsumerAdaptivePrefetch(t *testing.T) {
+	requireBroker(t)
+
+	consumerConfig := *Seasoning.ConsumerConfigs["TurboCookedRabbitConsumer-Ackable"]
+	consumerConfig.QueueName = "ConsumerPrefetchTestQueue"
+	consumerConfig.QosCountOverride = 1
+	consumerConfig.AdaptivePrefetch = &models.AdaptivePrefetch{MinPrefetch: 1, MaxPrefetch: 50, Interval: 50}
+	consumerConfig.HandlerConfig = &models.HandlerConfig{WorkerCount: 4}
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	topologer, err := topology.NewTopologer(channelPool)
+	assert.NoError(t, err)
+
+	err = topologer.CreateQueue("ConsumerPrefetchTestQueue", false, true, false, false, false, nil)
+	assert.NoError(t, err)
+
+	_, err = topologer.PurgeQueue("ConsumerPrefetchTestQueue", false)
+	assert.NoError(t, err)
+
+	publisher, err := publisher.NewPublisher(Seasoning, channelPool, nil)
+	assert.NoError(t, err)
+
+	for i := 0; i < 500; i++ {
+		publisher.Publish(utils.CreateMockRandomLetter("ConsumerPrefetchTestQueue"))
+		assert.True(t, (<-publisher.Notifications()).Success)
+	}
+
+	con, err := consumer.NewConsumerFromConfig(&consumerConfig, channelPool)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, con.Stats().Prefetch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	var handled int32
+	err = con.Handle(ctx, func(ctx context.Context, msg *models.Message) error {
+		time.Sleep(time.Millisecond)
+		if atomic.AddInt32(&handled, 1) == 500 {
+			go func() { assert.NoError(t, con.StopConsuming(false, false)) }()
+		}
+
+		return nil
+	})
+	assert.NoError(t, err)
+
+	stats := con.Stats()
+	assert.True(t, stats.Prefetch > 1) // grew while handlers waited on deliveries
+	assert.True(t, stats.Prefetch <= 50)
+	assert.Equal(t, uint64(500), stats.Handled)
+	assert.True(t, stats.HandlerLatency >= time.Millisecond)
+
+	channelPool.Shutdown()
+}
+
+func TestConsumerPauseResume(t *testing.T) {
+	requireBroker(t)
+
+	consumerConfig := *Seasoning.ConsumerConfigs["TurboCookedRabbitConsumer-Ackable"]
+	consumerConfig.QueueName = "ConsumerPauseTestQueue"
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	topologer, err := topology.NewTopologer(channelPool)
+	assert.NoError(t, err)
+
+	err = topologer.CreateQueue("ConsumerPauseTestQueue", false, true, false, false, false, nil)
+	assert.NoError(t, err)
+
+	_, err = topologer.PurgeQueue("ConsumerPauseTestQueue", false)
+	assert.NoError(t, err)
+
+	pub, err := publisher.NewPublisher(Seasoning, channelPool, nil)
+	assert.NoError(t, err)
+
+	con, err := consumer.NewConsumerFromConfig(&consumerConfig, channelPool)
+	assert.NoError(t, err)
+
+	assert.NoError(t, con.StartConsuming())
+
+	con.Pause()
+	assert.True(t, con.Paused())
+	assert.True(t, con.Stats().Paused)
+
+	time.Sleep(200 * time.Millisecond) // the consumer cancels its subscription
+
+	pub.Publish(utils.CreateMockRandomLetter("ConsumerPauseTestQueue"))
+	assert.True(t, (<-pub.Notifications()).Success)
+
+	select {
+	case <-con.Messages():
+		assert.Fail(t, "received a message while paused")
+	case <-time.After(time.Second):
+	}
+
+	con.Resume()
+	assert.False(t, con.Paused())
+
+	select {
+	case msg := <-con.Messages():
+		assert.NoError(t, msg.Acknowledge())
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "didn't receive the message after resuming")
+	}
+
+	assert.NoError(t, con.StopConsuming(false, false))
+	channelPool.Shutdown()
+}
+
+func TestConsumerStreamCheckpoints(t *testing.T) {
+	requireBroker(t)
+
+	directory, err := ioutil.TempDir("", "TurboCookedRabbitCheckpoints")
+	assert.NoError(t, err)
+	defer os.RemoveAll(directory)
+
+	consumerConfig := *Seasoning.ConsumerConfigs["TurboCookedRabbitConsumer-Ackable"]
+	consumerConfig.QueueName = "ConsumerStreamTestQueue"
+	consumerConfig.ConsumerName = "ConsumerStreamTest"
+	consumerConfig.QosCountOverride = 10
+	consumerConfig.StreamConfig = &models.StreamConfig{Offset: "first", CheckpointDirectory: directory}
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	topologer, err := topology.NewTopologer(channelPool)
+	assert.NoError(t, err)
+
+	_, _ = topologer.QueueDelete("ConsumerStreamTestQueue", false, false, false)
+	err = topologer.CreateQueue("ConsumerStreamTestQueue", false, true, false, false, false, map[string]interface{}{"x-queue-type": "stream"})
+	assert.NoError(t, err)
+
+	pub, err := publisher.NewPublisher(Seasoning, channelPool, nil)
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		pub.Publish(utils.CreateMockRandomLetter("ConsumerStreamTestQueue"))
+		assert.True(t, (<-pub.Notifications()).Success)
+	}
+
+	// Processes the first 5 messages then stops, checkpointing the offset.
+	con, err := consumer.NewConsumerFromConfig(&consumerConfig, channelPool)
+	assert.NoError(t, err)
+	assert.NoError(t, con.StartConsuming())
+
+	for i := 0; i < 5; i++ {
+		msg := <-con.Messages()
+		assert.NoError(t, msg.Acknowledge())
+	}
+
+	offset, ok := con.StreamOffset()
+	assert.True(t, ok)
+	assert.NoError(t, con.SaveCheckpoint())
+	assert.NoError(t, con.StopConsuming(true, true))
+
+	// Resumes after the checkpoint.
+	con, err = consumer.NewConsumerFromConfig(&consumerConfig, channelPool)
+	assert.NoError(t, err)
+	assert.NoError(t, con.StartConsuming())
+
+	msg := <-con.Messages()
+	assert.Equal(t, offset+1, msg.Headers[models.StreamOffsetHeader])
+	assert.NoError(t, msg.Acknowledge())
+	assert.NoError(t, con.StopConsuming(true, true))
+
+	// A stream needs a prefetch.
+	consumerConfig.QosCountOverride = 0
+	_, err = consumer.NewConsumerFromConfig(&consumerConfig, channelPool)
+	assert.Error(t, err)
+
+	channelPool.Shutdown()
+}
+
+func TestConsumerSingleActiveConsumer(t *testing.T) {
+	requireBroker(t)
+
+	queue := &models.Queue{
+		Name:    "ConsumerSingleActiveTestQueue",
+		Durable: true,
+		Args:    map[string]interface{}{"x-single-active-consumer": true},
+	}
+
+	consumerConfig := *Seasoning.ConsumerConfigs["TurboCookedRabbitConsumer-Ackable"]
+	consumerConfig.QueueName = "ConsumerSingleActiveTestQueue"
+	consumerConfig.QueueConfig = queue
+	consumerConfig.Priority = 10
+	consumerConfig.Args = map[string]interface{}{"x-custom-arg": "value"}
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	topologer, err := topology.NewTopologer(channelPool)
+	assert.NoError(t, err)
+
+	_, _ = topologer.QueueDelete("ConsumerSingleActiveTestQueue", false, false, false)
+
+	pub, err := publisher.NewPublisher(Seasoning, channelPool, nil)
+	assert.NoError(t, err)
+
+	first, err := consumer.NewConsumerFromConfig(&consumerConfig, channelPool)
+	assert.NoError(t, err)
+	assert.NoError(t, first.StartConsuming())
+
+	consumerConfig.ConsumerName = "SecondSingleActiveConsumer"
+	second, err := consumer.NewConsumerFromConfig(&consumerConfig, channelPool)
+	assert.NoError(t, err)
+	assert.NoError(t, second.StartConsuming())
+
+	time.Sleep(500 * time.Millisecond) // both subscribed
+	assert.False(t, first.Active())
+	assert.False(t, second.Active())
+
+	pub.Publish(utils.CreateMockRandomLetter("ConsumerSingleActiveTestQueue"))
+	assert.True(t, (<-pub.Notifications()).Success)
+
+	// The first consumer to subscribe is the active one.
+	select {
+	case msg := <-first.Messages():
+		assert.NoError(t, msg.Acknowledge())
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "the first consumer didn't receive the message")
+	}
+
+	assert.True(t, first.Active())
+	assert.True(t, first.Stats().Active)
+	assert.False(t, second.Active())
+
+	assert.NoError(t, first.StopConsuming(false, false))
+	assert.NoError(t, second.StopConsuming(false, false))
+
+	// Args have to be valid AMQP field values.
+	consumerConfig.Args = map[string]interface{}{"x-custom-arg": struct{}{}}
+	_, err = consumer.NewConsumerFromConfig(&consumerConfig, channelPool)
+	assert.Error(t, err)
+
+	channelPool.Shutdown()
+}
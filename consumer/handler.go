@@ -0,0 +1,347 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+)
+
+// Handler processes a message received by Consumer.Handle, the error it returns decides how the message is settled.
+type Handler func(ctx context.Context, msg *models.Message) error
+
+// RetryableError marks a handler error as temporary, the message is requeued (delayed through the retry queues when
+// the consumer has a RetryConfig).
+type RetryableError struct {
+	Err error
+}
+
+func (re *RetryableError) Error() string {
+	return re.Err.Error()
+}
+
+// Unwrap gets the error marked as retryable.
+func (re *RetryableError) Unwrap() error {
+	return re.Err
+}
+
+// Retryable marks err as temporary so Consumer.Handle requeues the message.
+func Retryable(err error) error {
+	return &RetryableError{Err: err}
+}
+
+// PermanentError marks a handler error as final, the message is rejected (dead-lettered when the queue has a DLX).
+type PermanentError struct {
+	Err error
+}
+
+func (pe *PermanentError) Error() string {
+	return pe.Err.Error()
+}
+
+// Unwrap gets the error marked as permanent.
+func (pe *PermanentError) Unwrap() error {
+	return pe.Err
+}
+
+// Permanent marks err as final so Consumer.Handle rejects the message.
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+// HandlerPanic is the error for a handler that panicked.
+type handlerPanic struct {
+	value interface{}
+}
+
+func (hp *handlerPanic) Error() string {
+	return fmt.Sprintf("handler panicked: %v", hp.value)
+}
+
+// ErrHandlerTimeout is reported when a handler runs longer than the HandlerConfig Timeout.
+var ErrHandlerTimeout = errors.New("handler timed out")
+
+// SetOutcomeMapper replaces how Consumer.Handle maps the error of a handler to an Outcome. Panics and timeouts use the
+// HandlerConfig outcomes. Nil restores the default: nil acks, Retryable errors requeue, Permanent errors reject and
+// any other error uses the HandlerConfig ErrorOutcome.
+func (con *Consumer) SetOutcomeMapper(mapper func(error) models.Outcome) {
+	con.handlerLock.Lock()
+	defer con.handlerLock.Unlock()
+
+	con.outcomeMapper = mapper
+}
+
+// Handle starts consuming and calls handler with every message until ctx is done, settling each message with the
+// outcome of its handler. A handler that panics is recovered and one that runs past the HandlerConfig Timeout is
+// abandoned (its message is settled right away so it may be redelivered while the handler still runs).
+// The HandlerConfig WorkerCount handlers run in parallel (no more than the QosCountOverride) so messages are not
+// handled in order when there's more than one.
+// Once ctx is done Handle stops consuming, requeues the ackable messages it didn't get to and returns ctx.Err().
+// Returns nil when the consumer is stopped with StopConsuming.
+func (con *Consumer) Handle(ctx context.Context, handler Handler) error {
+
+	if handler == nil {
+		return errors.New("can't handle messages without a handler")
+	}
+
+	if !con.Enabled {
+		return errors.New("can't handle messages with a disabled consumer")
+	}
+
+	if err := con.StartConsuming(); err != nil {
+		return err
+	}
+
+	con.conLock.Lock()
+	consumeDone := con.consumeDone
+	con.conLock.Unlock()
+
+	workers := &sync.WaitGroup{}
+	for i := 0; i < con.handlerWorkerCount(); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+
+			con.handleMessages(ctx, handler, consumeDone)
+		}()
+	}
+
+	workers.Wait()
+	return ctx.Err()
+}
+
+// HandlerWorkerCount gets how many handlers Handle runs in parallel. A worker waiting on a message the server won't
+// send before another is settled is of no use so there are never more workers than the QosCountOverride (or the
+// MaxPrefetch of an adaptive prefetch).
+func (con *Consumer) handlerWorkerCount() int {
+
+	workers := 1
+	if con.handlerConfig != nil && con.handlerConfig.WorkerCount > 1 {
+		workers = int(con.handlerConfig.WorkerCount)
+	}
+
+	maxWorkers := con.qosCountOverride
+	if con.prefetchTuner != nil {
+		maxWorkers = con.prefetchTuner.maxPrefetch
+	}
+
+	if maxWorkers > 0 && workers > maxWorkers {
+		workers = maxWorkers
+	}
+
+	return workers
+}
+
+// HandleMessages is a Handle worker, it handles messages until ctx is done or the consumer stops.
+func (con *Consumer) handleMessages(ctx context.Context, handler Handler, consumeDone chan struct{}) {
+
+	for {
+		select {
+		case msg := <-con.messages:
+			con.handleMessage(ctx, handler, msg)
+
+		case <-consumeDone: // a consumer holding its channel requeued what was left before closing it
+			con.drainMessages(func(msg *models.Message) { con.handleMessage(ctx, handler, msg) })
+			return
+
+		case <-ctx.Done():
+			_ = con.StopConsuming(false, false) // only the first worker gets to stop it
+
+			// The consumer finishes once every message received made it into the buffer.
+			for {
+				select {
+				case msg := <-con.messages:
+					con.abandonMessage(handler, msg)
+				case <-consumeDone:
+					con.drainMessages(func(msg *models.Message) { con.abandonMessage(handler, msg) })
+					return
+				}
+			}
+		}
+	}
+}
+
+// DrainMessages calls action with every message left in the buffer once the consumer stopped.
+func (con *Consumer) drainMessages(action func(*models.Message)) {
+	for {
+		select {
+		case msg := <-con.messages:
+			action(msg)
+		default:
+			return
+		}
+	}
+}
+
+// AbandonMessage requeues a message received after Handle's context is done. Messages that can't be requeued
+// (AutoAck) are still handled as they would be lost otherwise.
+func (con *Consumer) abandonMessage(handler Handler, msg *models.Message) {
+	if !msg.IsAckable {
+		con.handleMessage(context.Background(), handler, msg)
+		return
+	}
+
+	con.startInFlight()
+	defer con.finishInFlight()
+
+	if err := msg.Nack(true); err != nil {
+		con.handleError(fmt.Errorf("can't requeue message after the handler stopped: %s", err))
+	}
+}
+
+// HandleMessage runs the handler for a message and settles the message with the outcome. The message is in flight
+// until settled so the consumer doesn't release its channel before.
+func (con *Consumer) handleMessage(ctx context.Context, handler Handler, msg *models.Message) {
+
+	con.startInFlight()
+	defer con.finishInFlight()
+
+	start := time.Now()
+	outcome, err := con.runHandler(ctx, handler, msg)
+	atomic.AddUint64(&con.stats.handlerTime, uint64(time.Since(start)))
+	atomic.AddUint64(&con.stats.handled, 1)
+
+	if msg.IsAckable {
+		var settleErr error
+		switch outcome {
+		case models.OutcomeAck:
+			settleErr = msg.Acknowledge()
+		case models.OutcomeRequeue:
+			if con.retryConfig != nil {
+				settleErr = con.Retry(msg) // delayed instead of hot looping
+			} else {
+				settleErr = msg.Nack(true)
+			}
+		default:
+			settleErr = msg.Reject(false)
+		}
+
+		if settleErr != nil {
+			con.handleError(fmt.Errorf("can't settle message (%s): %s", outcome, settleErr))
+		}
+	}
+
+	var panicked *handlerPanic
+	if errors.Is(err, ErrHandlerTimeout) || errors.As(err, &panicked) {
+		con.handleError(err)
+	}
+}
+
+// RunHandler calls the handler, recovering a panic and enforcing the HandlerConfig Timeout, and maps the result to
+// an Outcome.
+func (con *Consumer) runHandler(ctx context.Context, handler Handler, msg *models.Message) (models.Outcome, error) {
+
+	config := con.handlerConfig
+	if config == nil {
+		config = &models.HandlerConfig{}
+	}
+
+	var handlerCtx context.Context
+	var cancel context.CancelFunc
+	if config.Timeout > 0 {
+		handlerCtx, cancel = context.WithTimeout(ctx, time.Duration(config.Timeout)*time.Millisecond)
+	} else {
+		handlerCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				result <- &handlerPanic{value: recovered}
+			}
+		}()
+
+		result <- handler(handlerCtx, msg)
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-handlerCtx.Done():
+		if ctx.Err() != nil {
+			err = <-result // Handle is stopping, the handler gets to finish
+			break
+		}
+
+		return outcomeOr(config.TimeoutOutcome, models.OutcomeRequeue),
+			fmt.Errorf("%w after %s", ErrHandlerTimeout, time.Duration(config.Timeout)*time.Millisecond)
+	}
+
+	var panicked *handlerPanic
+	if errors.As(err, &panicked) {
+		return outcomeOr(config.PanicOutcome, models.OutcomeReject), err
+	}
+
+	if err != nil && ctx.Err() != nil { // most likely failed because Handle is stopping, not because of the message
+		return models.OutcomeRequeue, err
+	}
+
+	con.handlerLock.RLock()
+	mapper := con.outcomeMapper
+	con.handlerLock.RUnlock()
+
+	if mapper != nil {
+		return mapper(err), err
+	}
+
+	return defaultOutcome(err, config), err
+}
+
+// DefaultOutcome maps a handler error to an Outcome when there's no outcome mapper.
+func defaultOutcome(err error, config *models.HandlerConfig) models.Outcome {
+
+	var retryable *RetryableError
+	var permanent *PermanentError
+
+	switch {
+	case err == nil:
+		return models.OutcomeAck
+	case errors.As(err, &retryable):
+		return models.OutcomeRequeue
+	case errors.As(err, &permanent):
+		return models.OutcomeReject
+	}
+
+	return outcomeOr(config.ErrorOutcome, models.OutcomeReject)
+}
+
+func outcomeOr(outcome models.Outcome, fallback models.Outcome) models.Outcome {
+	if outcome == "" {
+		return fallback
+	}
+
+	return outcome
+}
+
+func (con *Consumer) startInFlight() {
+	con.inFlightCond.L.Lock()
+	defer con.inFlightCond.L.Unlock()
+
+	con.inFlight++
+}
+
+func (con *Consumer) finishInFlight() {
+	con.inFlightCond.L.Lock()
+	defer con.inFlightCond.L.Unlock()
+
+	con.inFlight--
+	if con.inFlight == 0 {
+		con.inFlightCond.Broadcast()
+	}
+}
+
+// WaitInFlight blocks until no handler is running.
+func (con *Consumer) waitInFlight() {
+	con.inFlightCond.L.Lock()
+	defer con.inFlightCond.L.Unlock()
+
+	for con.inFlight > 0 {
+		con.inFlightCond.Wait()
+	}
+}
@@ -0,0 +1,74 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// PollDeliveries is how processDeliveries used to wait on deliveries: checking for one and sleeping on the
+// SleepOnIdleInterval when there's none. Kept as the baseline of BenchmarkDeliveryHandoff.
+func pollDeliveries(deliveries <-chan amqp.Delivery, messages chan<- amqp.Delivery, stop <-chan bool, idle time.Duration) {
+	for {
+		select {
+		case delivery := <-deliveries:
+			messages <- delivery
+		default:
+			time.Sleep(idle)
+		}
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+	}
+}
+
+// BlockOnDeliveries waits on deliveries the way processDeliveries does now, blocking until one or a stop arrives.
+func blockOnDeliveries(deliveries <-chan amqp.Delivery, messages chan<- amqp.Delivery, stop <-chan bool) {
+	for {
+		select {
+		case delivery := <-deliveries:
+			messages <- delivery
+		case <-stop:
+			return
+		}
+	}
+}
+
+// BenchmarkDeliveryHandoff measures the time from a delivery arriving to it being in the buffer of messages, with
+// the SleepOnIdleInterval loop (before) against blocking on deliveries (now).
+func BenchmarkDeliveryHandoff(b *testing.B) {
+
+	b.Run("SleepOnIdle", func(b *testing.B) {
+		benchmarkDeliveryHandoff(b, func(deliveries <-chan amqp.Delivery, messages chan<- amqp.Delivery, stop <-chan bool) {
+			pollDeliveries(deliveries, messages, stop, time.Millisecond)
+		})
+	})
+
+	b.Run("Blocking", func(b *testing.B) {
+		benchmarkDeliveryHandoff(b, blockOnDeliveries)
+	})
+}
+
+func benchmarkDeliveryHandoff(
+	b *testing.B,
+	loop func(deliveries <-chan amqp.Delivery, messages chan<- amqp.Delivery, stop <-chan bool)) {
+	b.ReportAllocs()
+
+	deliveries := make(chan amqp.Delivery)
+	messages := make(chan amqp.Delivery, 1)
+	stop := make(chan bool, 1)
+	go loop(deliveries, messages, stop)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		go func() { deliveries <- amqp.Delivery{} }()
+		<-messages
+	}
+	b.StopTimer()
+
+	stop <- true
+}
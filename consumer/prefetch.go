@@ -0,0 +1,148 @@
+package consumer
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+)
+
+// ConsumerStats holds the counters behind Consumer.Stats.
+type consumerStats struct {
+	received    uint64
+	handled     uint64
+	handlerTime uint64 // nanoseconds
+}
+
+// Stats lets you know how many messages the Consumer received and handled, how many are buffered, its current
+// prefetch and if it's paused or active.
+func (con *Consumer) Stats() *models.ConsumerStats {
+
+	stats := &models.ConsumerStats{
+		Received: atomic.LoadUint64(&con.stats.received),
+		Handled:  atomic.LoadUint64(&con.stats.handled),
+		Buffered: len(con.messages),
+		Prefetch: con.qosCountOverride,
+		Paused:   con.Paused(),
+		Active:   con.Active(),
+	}
+
+	if stats.Handled > 0 {
+		stats.HandlerLatency = time.Duration(atomic.LoadUint64(&con.stats.handlerTime) / stats.Handled)
+	}
+
+	if con.prefetchTuner != nil {
+		stats.Prefetch = con.prefetchTuner.current()
+	}
+
+	return stats
+}
+
+// PrefetchTuner adjusts a consumer's prefetch (AIMD) from what happened since its last adjustment.
+type prefetchTuner struct {
+	prefetch      int32
+	minPrefetch   int
+	maxPrefetch   int
+	interval      time.Duration
+	maxBufferTime time.Duration
+	last          consumerStats
+}
+
+func newPrefetchTuner(config *models.AdaptivePrefetch, initial int) *prefetchTuner {
+
+	tuner := &prefetchTuner{
+		minPrefetch:   config.MinPrefetch,
+		maxPrefetch:   config.MaxPrefetch,
+		interval:      time.Duration(config.Interval) * time.Millisecond,
+		maxBufferTime: time.Duration(config.MaxBufferTime) * time.Millisecond,
+	}
+
+	if tuner.minPrefetch < 1 {
+		tuner.minPrefetch = 1
+	}
+
+	if tuner.interval <= 0 {
+		tuner.interval = time.Second
+	}
+
+	if tuner.maxBufferTime <= 0 {
+		tuner.maxBufferTime = time.Second
+	}
+
+	tuner.prefetch = int32(tuner.clamp(initial))
+	return tuner
+}
+
+func (pt *prefetchTuner) current() int {
+	return int(atomic.LoadInt32(&pt.prefetch))
+}
+
+func (pt *prefetchTuner) clamp(prefetch int) int {
+	if prefetch < pt.minPrefetch {
+		return pt.minPrefetch
+	}
+
+	if prefetch > pt.maxPrefetch {
+		return pt.maxPrefetch
+	}
+
+	return prefetch
+}
+
+// Adjust picks the next prefetch, returns false when it didn't change.
+// Over-buffering (the buffer over half full or holding more handler work than the MaxBufferTime) halves it. Deliveries
+// arriving while the buffer is nearly empty means handlers wait on the server so it grows by a quarter.
+func (pt *prefetchTuner) adjust(stats consumerStats, buffered int, bufferSize int, workers int) (int, bool) {
+
+	received := stats.received - pt.last.received
+	handled := stats.handled - pt.last.handled
+	handlerTime := stats.handlerTime - pt.last.handlerTime
+	pt.last = stats
+
+	var bufferTime time.Duration
+	if handled > 0 && workers > 0 {
+		bufferTime = time.Duration(handlerTime/handled) * time.Duration(buffered) / time.Duration(workers)
+	}
+
+	prefetch := pt.current()
+	next := prefetch
+
+	switch {
+	case buffered*2 > bufferSize || bufferTime > pt.maxBufferTime:
+		next = prefetch / 2
+	case received > 0 && buffered*4 <= prefetch:
+		step := prefetch / 4
+		if step < 1 {
+			step = 1
+		}
+
+		next = prefetch + step
+	}
+
+	next = pt.clamp(next)
+	if next == prefetch {
+		return prefetch, false
+	}
+
+	atomic.StoreInt32(&pt.prefetch, int32(next))
+	return next, true
+}
+
+// TunePrefetch adjusts the prefetch of the consumer's channel.
+func (con *Consumer) tunePrefetch(apply func(prefetch int) error) {
+
+	stats := consumerStats{
+		received:    atomic.LoadUint64(&con.stats.received),
+		handled:     atomic.LoadUint64(&con.stats.handled),
+		handlerTime: atomic.LoadUint64(&con.stats.handlerTime),
+	}
+
+	prefetch, changed := con.prefetchTuner.adjust(stats, len(con.messages), cap(con.messages), con.handlerWorkerCount())
+	if !changed {
+		return
+	}
+
+	if err := apply(prefetch); err != nil {
+		con.handleError(err)
+	}
+}
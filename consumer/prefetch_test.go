@@ -0,0 +1,112 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+)
+
+func TestPrefetchTunerAdjust(t *testing.T) {
+
+	config := &models.AdaptivePrefetch{MinPrefetch: 3, MaxPrefetch: 32, MaxBufferTime: 1000}
+
+	tests := []struct {
+		name     string
+		initial  int
+		stats    consumerStats
+		buffered int
+		prefetch int
+		changed  bool
+	}{
+		{
+			name:     "grows by a quarter",
+			initial:  8,
+			stats:    consumerStats{received: 10},
+			prefetch: 10,
+			changed:  true,
+		},
+		{
+			name:     "grows by at least one",
+			initial:  3,
+			stats:    consumerStats{received: 10},
+			prefetch: 4,
+			changed:  true,
+		},
+		{
+			name:     "grows up to the max",
+			initial:  30,
+			stats:    consumerStats{received: 10},
+			prefetch: 32,
+			changed:  true,
+		},
+		{
+			name:     "stays at the max",
+			initial:  32,
+			stats:    consumerStats{received: 10},
+			prefetch: 32,
+		},
+		{
+			name:     "stays without deliveries",
+			initial:  8,
+			prefetch: 8,
+		},
+		{
+			name:     "stays while messages wait in the buffer",
+			initial:  8,
+			stats:    consumerStats{received: 10},
+			buffered: 3,
+			prefetch: 8,
+		},
+		{
+			name:     "halves with the buffer over half full",
+			initial:  20,
+			stats:    consumerStats{received: 10},
+			buffered: 60,
+			prefetch: 10,
+			changed:  true,
+		},
+		{
+			name:     "halves down to the min",
+			initial:  4,
+			stats:    consumerStats{received: 10},
+			buffered: 60,
+			prefetch: 3,
+			changed:  true,
+		},
+		{
+			name:     "halves with more buffered work than the MaxBufferTime",
+			initial:  20,
+			stats:    consumerStats{received: 10, handled: 10, handlerTime: uint64(10 * 100 * time.Millisecond)},
+			buffered: 30, // 1.5s of handler work for the 2 workers
+			prefetch: 10,
+			changed:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tuner := newPrefetchTuner(config, test.initial)
+
+			prefetch, changed := tuner.adjust(test.stats, test.buffered, 100, 2)
+			assert.Equal(t, test.prefetch, prefetch)
+			assert.Equal(t, test.changed, changed)
+			assert.Equal(t, test.prefetch, tuner.current())
+		})
+	}
+}
+
+func TestPrefetchTunerAdjustSinceLast(t *testing.T) {
+
+	tuner := newPrefetchTuner(&models.AdaptivePrefetch{MaxPrefetch: 100}, 8)
+
+	_, changed := tuner.adjust(consumerStats{received: 10}, 0, 100, 1)
+	assert.True(t, changed)
+
+	// Nothing was received since the last adjustment.
+	prefetch, changed := tuner.adjust(consumerStats{received: 10}, 0, 100, 1)
+	assert.False(t, changed)
+	assert.Equal(t, 10, prefetch)
+}
@@ -0,0 +1,231 @@
+package consumer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+)
+
+// Reassembler puts chunked letters back together, buffering chunks until every chunk of a group has arrived.
+// Buffered chunks stay unacknowledged so they count against the prefetch, a group needing more chunks than the
+// prefetch would never complete.
+type Reassembler struct {
+	timeout        time.Duration
+	maxBytes       uint64
+	maxChunks      int64
+	bufferedBytes  uint64
+	bufferedChunks int64
+	groups         map[string]*chunkGroup
+	completed      map[string]time.Time
+	lock           *sync.Mutex
+}
+
+// ChunkGroup holds the chunks of one letter received so far, duplicates are kept to be acknowledged with the rest.
+type chunkGroup struct {
+	chunks     []*models.Message
+	duplicates []*models.Message
+	received   int
+	bytes      uint64
+	firstSeen  time.Time
+}
+
+// NewReassembler creates a Reassembler that gives up on a chunk group after timeout (zero never gives up) and
+// refuses chunks once maxBytes (zero is unlimited) are buffered. Completed groups are remembered for the timeout
+// so chunks of a letter published again (a publish retried after its confirmation was lost) are acknowledged
+// instead of starting a group that never completes. The maxChunks (zero is unlimited) is the consumer's
+// prefetch: groups of more chunks are refused and so is a chunk that would fill the prefetch without completing
+// its group.
+func NewReassembler(timeout time.Duration, maxBytes uint64, maxChunks int) *Reassembler {
+	return &Reassembler{
+		timeout:   timeout,
+		maxBytes:  maxBytes,
+		maxChunks: int64(maxChunks),
+		groups:    make(map[string]*chunkGroup),
+		completed: make(map[string]time.Time),
+		lock:      &sync.Mutex{},
+	}
+}
+
+// Add takes a Message and returns the Message to deliver: the Message itself when it isn't a chunk, nil while its
+// chunk group is incomplete, or the reassembled Message once the last chunk arrives. A chunk of a recently
+// completed group is acknowledged and nil is returned.
+// Returns an error, without keeping the chunk, when its chunk headers are invalid, its group needs more chunks than
+// the prefetch or the memory or prefetch limit is reached.
+func (r *Reassembler) Add(msg *models.Message) (*models.Message, error) {
+
+	groupID, ok := msg.Headers[models.ChunkGroupHeader].(string)
+	if !ok {
+		return msg, nil
+	}
+
+	seq, seqOk := headerInt(msg.Headers[models.ChunkSeqHeader])
+	total, totalOk := headerInt(msg.Headers[models.ChunkTotalHeader])
+	if !seqOk || !totalOk || total < 1 || seq < 0 || seq >= total {
+		return nil, fmt.Errorf("invalid chunk headers for chunk group %s", groupID)
+	}
+
+	if r.maxChunks > 0 && total > r.maxChunks {
+		return nil, fmt.Errorf("chunk group %s has %d chunks, more than the prefetch of %d", groupID, total, r.maxChunks)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, completed := r.completed[groupID]; completed {
+		if msg.IsAckable {
+			return nil, msg.Acknowledge()
+		}
+
+		return nil, nil
+	}
+
+	group, ok := r.groups[groupID]
+	if !ok {
+		group = &chunkGroup{
+			chunks:    make([]*models.Message, total),
+			firstSeen: time.Now(),
+		}
+		r.groups[groupID] = group
+	} else if int64(len(group.chunks)) != total {
+		return nil, fmt.Errorf("chunk total changed for chunk group %s", groupID)
+	}
+
+	duplicate := group.chunks[seq] != nil
+	completes := !duplicate && group.received+1 == len(group.chunks)
+	if !completes && r.maxChunks > 0 && r.bufferedChunks+1 >= r.maxChunks {
+		r.dropIfEmpty(groupID, group)
+		return nil, errors.New("can't buffer chunk, the prefetch is full of incomplete chunk groups")
+	}
+
+	if duplicate {
+		group.duplicates = append(group.duplicates, msg)
+		r.bufferedChunks++
+		return nil, nil
+	}
+
+	size := uint64(len(msg.Body))
+	if r.maxBytes > 0 && r.bufferedBytes+size > r.maxBytes {
+		r.dropIfEmpty(groupID, group)
+		return nil, errors.New("can't buffer chunk, reassembly memory limit reached")
+	}
+
+	group.chunks[seq] = msg
+	group.received++
+	group.bytes += size
+	r.bufferedBytes += size
+	r.bufferedChunks++
+
+	if !completes {
+		return nil, nil
+	}
+
+	delete(r.groups, groupID)
+	r.bufferedBytes -= group.bytes
+	r.bufferedChunks -= int64(group.received + len(group.duplicates))
+
+	if r.timeout > 0 {
+		r.completed[groupID] = time.Now()
+	}
+
+	return group.reassemble(), nil
+}
+
+// Sweep removes the chunk groups that timed out and returns their chunks so they can be rejected, completed groups
+// are forgotten after the timeout too.
+func (r *Reassembler) Sweep(now time.Time) []*models.Message {
+
+	if r.timeout <= 0 {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for groupID, completedAt := range r.completed {
+		if now.Sub(completedAt) >= r.timeout {
+			delete(r.completed, groupID)
+		}
+	}
+
+	var expired []*models.Message
+	for groupID, group := range r.groups {
+		if now.Sub(group.firstSeen) < r.timeout {
+			continue
+		}
+
+		for _, chunk := range group.chunks {
+			if chunk != nil {
+				expired = append(expired, chunk)
+			}
+		}
+
+		expired = append(expired, group.duplicates...)
+		r.bufferedBytes -= group.bytes
+		r.bufferedChunks -= int64(group.received + len(group.duplicates))
+		delete(r.groups, groupID)
+	}
+
+	return expired
+}
+
+// DropIfEmpty forgets a group created for a chunk that couldn't be kept.
+func (r *Reassembler) dropIfEmpty(groupID string, group *chunkGroup) {
+	if group.received == 0 {
+		delete(r.groups, groupID)
+	}
+}
+
+// BufferedBytes lets you know how many chunk bytes are waiting on the rest of their chunk group.
+func (r *Reassembler) BufferedBytes() uint64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.bufferedBytes
+}
+
+// Reassemble joins the chunk bodies in order, the headers come from the first chunk without the chunk headers.
+func (group *chunkGroup) reassemble() *models.Message {
+
+	body := make([]byte, 0, group.bytes)
+	for _, chunk := range group.chunks {
+		body = append(body, chunk.Body...)
+	}
+
+	headers := make(map[string]interface{}, len(group.chunks[0].Headers))
+	for key, value := range group.chunks[0].Headers {
+		headers[key] = value
+	}
+
+	delete(headers, models.ChunkGroupHeader)
+	delete(headers, models.ChunkSeqHeader)
+	delete(headers, models.ChunkTotalHeader)
+
+	return models.NewReassembledMessage(headers, body, append(group.chunks, group.duplicates...))
+}
+
+// HeaderInt reads an integer header whatever integer type it arrived as.
+func headerInt(value interface{}) (int64, bool) {
+	switch number := value.(type) {
+	case int:
+		return int64(number), true
+	case int8:
+		return int64(number), true
+	case int16:
+		return int64(number), true
+	case int32:
+		return int64(number), true
+	case int64:
+		return number, true
+	case uint8:
+		return int64(number), true
+	case uint16:
+		return int64(number), true
+	case uint32:
+		return int64(number), true
+	}
+
+	return 0, false
+}
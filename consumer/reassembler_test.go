@@ -0,0 +1,152 @@
+package consumer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/prom3t3us/turbocookedrabbit/consumer"
+	"github.com/prom3t3us/turbocookedrabbit/models"
+)
+
+func createChunk(group string, seq int64, total int64, body string) *models.Message {
+	return models.NewMessage(
+		false,
+		map[string]interface{}{
+			models.ChunkGroupHeader: group,
+			models.ChunkSeqHeader:   seq,
+			models.ChunkTotalHeader: total,
+			"x-custom":              "kept",
+		},
+		[]byte(body),
+		uint64(seq),
+		nil)
+}
+
+func TestReassemblerOutOfOrderAndDuplicateChunks(t *testing.T) {
+
+	reassembler := consumer.NewReassembler(time.Minute, 0, 0)
+
+	for _, c := range []*models.Message{createChunk("Group", 2, 3, "ghi"), createChunk("Group", 0, 3, "abc"), createChunk("Group", 2, 3, "ghi")} {
+		message, err := reassembler.Add(c)
+		assert.NoError(t, err)
+		assert.Nil(t, message)
+	}
+
+	assert.Equal(t, uint64(6), reassembler.BufferedBytes())
+
+	message, err := reassembler.Add(createChunk("Group", 1, 3, "def"))
+	assert.NoError(t, err)
+	assert.Equal(t, "abcdefghi", string(message.Body))
+	assert.Equal(t, "kept", message.Headers["x-custom"])
+	assert.Nil(t, message.Headers[models.ChunkSeqHeader])
+	assert.Equal(t, uint64(0), reassembler.BufferedBytes())
+
+	// Messages that aren't chunks pass straight through.
+	plain := models.NewMessage(false, nil, []byte("plain"), 4, nil)
+	message, err = reassembler.Add(plain)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, message)
+
+	// Incomplete groups are handed back once they time out.
+	_, err = reassembler.Add(createChunk("Other", 0, 3, "abc"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(reassembler.Sweep(time.Now())))
+	assert.Equal(t, 1, len(reassembler.Sweep(time.Now().Add(2*time.Minute))))
+}
+
+func TestReassemblerMemoryLimit(t *testing.T) {
+
+	reassembler := consumer.NewReassembler(time.Minute, 5, 0)
+
+	chunk := createChunk("Group", 0, 2, "toolarge")
+
+	message, err := reassembler.Add(chunk)
+	assert.Error(t, err)
+	assert.Nil(t, message)
+	assert.Equal(t, uint64(0), reassembler.BufferedBytes())
+}
+
+func TestReassemblerPrefetchLimit(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		maxChunks int
+		chunks    []*models.Message
+		errors    []bool
+		buffered  uint64
+	}{
+		{
+			name:      "unlimited",
+			maxChunks: 0,
+			chunks:    []*models.Message{createChunk("A", 0, 100, "a")},
+			errors:    []bool{false},
+			buffered:  1,
+		},
+		{
+			name:      "group as large as the prefetch",
+			maxChunks: 2,
+			chunks:    []*models.Message{createChunk("A", 0, 2, "a"), createChunk("A", 1, 2, "b")},
+			errors:    []bool{false, false},
+			buffered:  0,
+		},
+		{
+			name:      "group larger than the prefetch",
+			maxChunks: 2,
+			chunks:    []*models.Message{createChunk("A", 0, 3, "a")},
+			errors:    []bool{true},
+			buffered:  0,
+		},
+		{
+			name:      "prefetch full of incomplete groups",
+			maxChunks: 2,
+			chunks:    []*models.Message{createChunk("A", 0, 2, "a"), createChunk("B", 0, 2, "b"), createChunk("A", 1, 2, "c")},
+			errors:    []bool{false, true, false},
+			buffered:  0,
+		},
+		{
+			name:      "duplicates count against the prefetch",
+			maxChunks: 3,
+			chunks:    []*models.Message{createChunk("A", 0, 3, "a"), createChunk("A", 0, 3, "a"), createChunk("A", 1, 3, "b")},
+			errors:    []bool{false, false, true},
+			buffered:  1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reassembler := consumer.NewReassembler(time.Minute, 0, test.maxChunks)
+
+			for i, chunk := range test.chunks {
+				_, err := reassembler.Add(chunk)
+				assert.Equal(t, test.errors[i], err != nil, "chunk %d", i)
+			}
+
+			assert.Equal(t, test.buffered, reassembler.BufferedBytes())
+		})
+	}
+}
+
+func TestReassemblerCompletedGroupChunks(t *testing.T) {
+
+	reassembler := consumer.NewReassembler(time.Minute, 0, 0)
+
+	_, err := reassembler.Add(createChunk("Group", 0, 2, "abc"))
+	assert.NoError(t, err)
+	message, err := reassembler.Add(createChunk("Group", 1, 2, "def"))
+	assert.NoError(t, err)
+	assert.Equal(t, "abcdef", string(message.Body))
+
+	// Chunks of a letter published again don't start a new group.
+	message, err = reassembler.Add(createChunk("Group", 0, 2, "abc"))
+	assert.NoError(t, err)
+	assert.Nil(t, message)
+	assert.Equal(t, uint64(0), reassembler.BufferedBytes())
+
+	// Completed groups are forgotten once they time out.
+	assert.Equal(t, 0, len(reassembler.Sweep(time.Now().Add(2*time.Minute))))
+	_, err = reassembler.Add(createChunk("Group", 0, 2, "abc"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), reassembler.BufferedBytes())
+}
@@ -0,0 +1,253 @@
+package consumer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+	"github.com/prom3t3us/turbocookedrabbit/pools"
+	"github.com/prom3t3us/turbocookedrabbit/topology"
+	"github.com/streadway/amqp"
+)
+
+// RetryQueue gets the name of the queue holding messages waiting on their attempt retry (starting at 1).
+func (con *Consumer) RetryQueue(attempt int) string {
+	return fmt.Sprintf("%s.retry.%d", con.QueueName, attempt)
+}
+
+// ParkingLotQueue gets the name of the queue keeping messages that failed after their last retry.
+func (con *Consumer) ParkingLotQueue() string {
+	return con.QueueName + ".parkinglot"
+}
+
+// DeclareRetryQueues declares the retry queues of every RetryConfig tier and the parking lot queue. A retry queue
+// holds messages for its delay (x-message-ttl) then dead-letters them back to the consumer's queue. Retry declares
+// them on first use, call it to declare them up front.
+func (con *Consumer) DeclareRetryQueues() error {
+
+	if con.retryConfig == nil {
+		return errors.New("can't declare retry queues without a RetryConfig")
+	}
+
+	con.retryLock.Lock()
+	defer con.retryLock.Unlock()
+
+	if con.retryQueuesDeclared {
+		return nil
+	}
+
+	topologer, err := topology.NewTopologer(con.channelPool)
+	if err != nil {
+		return err
+	}
+
+	for i, delay := range con.retryConfig.Delays {
+		err = topologer.CreateQueue(
+			con.RetryQueue(i+1),
+			false, // passiveDeclare
+			true,  // durable
+			false, // autoDelete
+			false, // exclusive
+			false, // noWait
+			map[string]interface{}{
+				"x-message-ttl":             int64(delay),
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": con.QueueName,
+			})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = topologer.CreateQueue(con.ParkingLotQueue(), false, true, false, false, false, nil); err != nil {
+		return err
+	}
+
+	con.retryQueuesDeclared = true
+	return nil
+}
+
+// RetryChannel is the channel in confirm mode Retry publishes on, kept until the consumer stops or the channel fails.
+type retryChannel struct {
+	chanHost *pools.ChannelHost
+	confirms chan amqp.Confirmation
+}
+
+// Retry republishes the message, with its properties, to the retry queue of its next attempt, or to the parking lot
+// queue once it went through every retry tier, then acknowledges it once the server confirmed the copy. The attempt is
+// kept in the RetryAttemptHeader. A claim-checked message is republished without its Body, the claim-check still
+// refers to it, and a reassembled message is republished as the chunks it arrived in. Needs a RetryConfig.
+func (con *Consumer) Retry(msg *models.Message) error {
+
+	if err := con.DeclareRetryQueues(); err != nil {
+		return err
+	}
+
+	attempt := retryAttempt(msg) + 1
+
+	queueName := con.ParkingLotQueue()
+	if attempt <= len(con.retryConfig.Delays) {
+		queueName = con.RetryQueue(attempt)
+	}
+
+	var publishings []amqp.Publishing
+	if chunks := msg.Chunks(); chunks != nil {
+		sent := make(map[int64]bool, len(chunks))
+		for _, chunk := range chunks {
+			seq, _ := headerInt(chunk.Headers[models.ChunkSeqHeader])
+			if sent[seq] {
+				continue // a duplicate
+			}
+
+			sent[seq] = true
+			publishing := retryPublishing(chunk, attempt)
+
+			// The consumer remembers the group completed, the copy is a group of its own.
+			groupID, _ := chunk.Headers[models.ChunkGroupHeader].(string)
+			publishing.Headers[models.ChunkGroupHeader] = fmt.Sprintf("%s.retry.%d", groupID, attempt)
+			publishings = append(publishings, publishing)
+		}
+	} else {
+		publishings = append(publishings, retryPublishing(msg, attempt))
+	}
+
+	if err := con.publishConfirmed(queueName, publishings); err != nil {
+		return fmt.Errorf("can't republish message to %s: %s", queueName, err)
+	}
+
+	if msg.IsAckable {
+		return msg.Acknowledge()
+	}
+
+	return nil
+}
+
+// RetryPublishing copies the message, with its properties, for its attempt retry.
+func retryPublishing(msg *models.Message, attempt int) amqp.Publishing {
+
+	headers := make(amqp.Table, len(msg.Headers)+1)
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	headers[models.RetryAttemptHeader] = int32(attempt)
+
+	body := msg.Body
+	if _, ok := msg.ClaimCheckKey(); ok {
+		body = nil // inlined from the BlobStore, which still has it
+	}
+
+	return amqp.Publishing{
+		Headers:         headers,
+		Body:            body,
+		DeliveryMode:    msg.DeliveryMode,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		MessageId:       msg.MessageID,
+		CorrelationId:   msg.CorrelationID,
+		ReplyTo:         msg.ReplyTo,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		AppId:           msg.AppID,
+		Priority:        msg.Priority,
+	}
+}
+
+// PublishConfirmed publishes to the queue on the retry channel and waits for the server to confirm every publishing.
+// A channel that fails is closed, so it's recreated outside confirm mode, and the next retry leases another one.
+func (con *Consumer) publishConfirmed(queueName string, publishings []amqp.Publishing) error {
+
+	con.retryLock.Lock()
+	defer con.retryLock.Unlock()
+
+	if con.retryChannel == nil {
+		rc, err := con.openRetryChannel()
+		if err != nil {
+			return err
+		}
+
+		con.retryChannel = rc
+	}
+
+	err := con.retryChannel.publish(queueName, publishings)
+	if err != nil {
+		con.discardRetryChannel()
+	}
+
+	return err
+}
+
+// OpenRetryChannel leases a channel from the ChannelPool and puts it in confirm mode.
+func (con *Consumer) openRetryChannel() (*retryChannel, error) {
+
+	chanHost, err := con.channelPool.GetChannel()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = chanHost.Channel.Confirm(false); err != nil {
+		con.channelPool.FlagChannel(chanHost.ChannelID)
+		_ = chanHost.Channel.Close()
+		con.channelPool.ReturnChannel(chanHost, false)
+		return nil, fmt.Errorf("can't put the channel in confirm mode: %s", err)
+	}
+
+	return &retryChannel{
+		chanHost: chanHost,
+		confirms: chanHost.Channel.NotifyPublish(make(chan amqp.Confirmation, 1)),
+	}, nil
+}
+
+// Publish publishes to the queue and waits for the confirm of every publishing, in order as they are one at a time.
+func (rc *retryChannel) publish(queueName string, publishings []amqp.Publishing) error {
+
+	for _, publishing := range publishings {
+		err := rc.chanHost.Channel.Publish(
+			"", // default exchange routes straight to the queue
+			queueName,
+			false, // mandatory
+			false, // immediate
+			publishing)
+		if err != nil {
+			return err
+		}
+
+		confirmation, ok := <-rc.confirms // closed with the channel
+		switch {
+		case !ok:
+			return errors.New("channel closed before the server confirmed")
+		case !confirmation.Ack:
+			return errors.New("the server nacked the message")
+		}
+	}
+
+	return nil
+}
+
+// DiscardRetryChannel closes the retry channel and has the ChannelPool replace it, the retryLock must be held.
+func (con *Consumer) discardRetryChannel() {
+	if con.retryChannel == nil {
+		return
+	}
+
+	con.channelPool.FlagChannel(con.retryChannel.chanHost.ChannelID)
+	_ = con.retryChannel.chanHost.Channel.Close()
+	con.channelPool.ReturnChannel(con.retryChannel.chanHost, false)
+	con.retryChannel = nil
+}
+
+// CloseRetryChannel discards the retry channel once the consumer stops, a later Retry leases another one.
+func (con *Consumer) closeRetryChannel() {
+	con.retryLock.Lock()
+	defer con.retryLock.Unlock()
+
+	con.discardRetryChannel()
+}
+
+// RetryAttempt gets how many times the message was retried, 0 when it wasn't.
+func retryAttempt(msg *models.Message) int {
+	if attempt, ok := headerInt(msg.Headers[models.RetryAttemptHeader]); ok && attempt > 0 {
+		return int(attempt)
+	}
+
+	return 0
+}
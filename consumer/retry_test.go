@@ -0,0 +1,29 @@
+package consumer
+
+import (
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+)
+
+func TestRetryPublishing(t *testing.T) {
+
+	msg := models.NewMessage(false, map[string]interface{}{"x-custom": "kept"}, []byte("body"), 1, nil)
+	msg.DeliveryMode = amqp.Transient
+
+	publishing := retryPublishing(msg, 2)
+	assert.Equal(t, []byte("body"), publishing.Body)
+	assert.Equal(t, amqp.Transient, publishing.DeliveryMode)
+	assert.Equal(t, "kept", publishing.Headers["x-custom"])
+	assert.Equal(t, int32(2), publishing.Headers[models.RetryAttemptHeader])
+	assert.Nil(t, msg.Headers[models.RetryAttemptHeader])
+
+	// Claim-checked bodies stay in the BlobStore.
+	msg.Headers[models.ClaimCheckHeader] = "BlobKey"
+	publishing = retryPublishing(msg, 1)
+	assert.Nil(t, publishing.Body)
+	assert.Equal(t, "BlobKey", publishing.Headers[models.ClaimCheckHeader])
+}
@@ -0,0 +1,292 @@
+package consumer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+	"github.com/prom3t3us/turbocookedrabbit/utils"
+	"github.com/streadway/amqp"
+)
+
+// StreamState is where a stream consumer is in its stream, kept across channels.
+type streamState struct {
+	offset       interface{} // x-stream-offset used without a checkpoint
+	interval     time.Duration
+	store        utils.CheckpointStore
+	loaded       bool
+	processed    int64 // last offset processed
+	hasProcessed bool
+	saved        int64
+	hasSaved     bool
+	lock         *sync.Mutex
+}
+
+func newStreamState(config *models.StreamConfig) (*streamState, error) {
+
+	offset, err := parseStreamOffset(config.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &streamState{
+		offset:   offset,
+		interval: time.Duration(config.CheckpointInterval) * time.Millisecond,
+		lock:     &sync.Mutex{},
+	}
+
+	if stream.interval <= 0 {
+		stream.interval = time.Second
+	}
+
+	if config.CheckpointDirectory != "" {
+		if stream.store, err = utils.NewFileCheckpointStore(config.CheckpointDirectory); err != nil {
+			return nil, err
+		}
+	}
+
+	return stream, nil
+}
+
+// ParseStreamOffset gets the x-stream-offset for an Offset of the StreamConfig.
+func parseStreamOffset(offset string) (interface{}, error) {
+
+	switch offset {
+	case "":
+		return "next", nil
+	case "first", "last", "next":
+		return offset, nil
+	}
+
+	if number, err := strconv.ParseInt(offset, 10, 64); err == nil {
+		if number < 0 {
+			return nil, fmt.Errorf("stream offset can't be negative: %d", number)
+		}
+
+		return number, nil
+	}
+
+	if timestamp, err := time.Parse(time.RFC3339, offset); err == nil {
+		return timestamp, nil
+	}
+
+	return nil, fmt.Errorf("invalid stream offset %q, expecting first, last, next, an offset or an RFC3339 timestamp", offset)
+}
+
+// SetCheckpointStore replaces where the stream consumer keeps its checkpoints, set it before it starts consuming.
+// Nil only keeps the offset in memory, across channels but not restarts.
+func (con *Consumer) SetCheckpointStore(store utils.CheckpointStore) error {
+
+	if con.stream == nil {
+		return errors.New("can't checkpoint a consumer without a StreamConfig")
+	}
+
+	con.stream.lock.Lock()
+	defer con.stream.lock.Unlock()
+
+	con.stream.store = store
+	con.stream.loaded = false
+	return nil
+}
+
+// StreamOffset gets the last offset the stream consumer processed (acked, nacked or rejected every message up to).
+// False when it didn't process any message yet.
+func (con *Consumer) StreamOffset() (int64, bool) {
+
+	if con.stream == nil {
+		return 0, false
+	}
+
+	con.stream.lock.Lock()
+	defer con.stream.lock.Unlock()
+
+	return con.stream.processed, con.stream.hasProcessed
+}
+
+// SaveCheckpoint saves the StreamOffset to the CheckpointStore now instead of waiting on the CheckpointInterval.
+func (con *Consumer) SaveCheckpoint() error {
+
+	if con.stream == nil {
+		return errors.New("can't checkpoint a consumer without a StreamConfig")
+	}
+
+	return con.stream.checkpoint(con.checkpointKey())
+}
+
+// CheckpointKey gets the key of the consumer's checkpoint, its ConsumerName or QueueName without one.
+func (con *Consumer) checkpointKey() string {
+	if con.ConsumerName != "" {
+		return con.ConsumerName
+	}
+
+	return con.QueueName
+}
+
+// ConsumeOffset gets the x-stream-offset to subscribe with: right after the last offset processed (or checkpointed
+// by a previous run) or the StreamConfig Offset.
+func (stream *streamState) consumeOffset(key string) (interface{}, error) {
+	stream.lock.Lock()
+	defer stream.lock.Unlock()
+
+	if !stream.loaded && stream.store != nil {
+		offset, ok, err := stream.store.Load(key)
+		if err != nil {
+			return nil, fmt.Errorf("can't load the stream checkpoint: %s", err)
+		}
+
+		if ok && (!stream.hasProcessed || offset > stream.processed) {
+			stream.processed, stream.hasProcessed = offset, true
+			stream.saved, stream.hasSaved = offset, true
+		}
+
+		stream.loaded = true
+	}
+
+	if stream.hasProcessed {
+		return stream.processed + 1, nil
+	}
+
+	return stream.offset, nil
+}
+
+func (stream *streamState) process(offset int64) {
+	stream.lock.Lock()
+	defer stream.lock.Unlock()
+
+	if !stream.hasProcessed || offset > stream.processed {
+		stream.processed, stream.hasProcessed = offset, true
+	}
+}
+
+// Checkpoint saves the last offset processed when it changed since the last checkpoint.
+func (stream *streamState) checkpoint(key string) error {
+	stream.lock.Lock()
+	defer stream.lock.Unlock()
+
+	if stream.store == nil || !stream.hasProcessed || (stream.hasSaved && stream.saved == stream.processed) {
+		return nil
+	}
+
+	if err := stream.store.Save(key, stream.processed); err != nil {
+		return fmt.Errorf("can't save the stream checkpoint: %s", err)
+	}
+
+	stream.saved, stream.hasSaved = stream.processed, true
+	return nil
+}
+
+// OffsetTracker follows the deliveries of a stream consumer's channel as they are settled. Messages can be settled
+// out of order so an offset only counts as processed once every delivery before it on the channel is settled too.
+type offsetTracker struct {
+	channel amqp.Acknowledger
+	stream  *streamState
+	floor   uint64           // every delivery up to floor is settled
+	offsets map[uint64]int64 // offset of the deliveries above the floor, -1 without one
+	settled map[uint64]bool
+	last    int64 // last offset received, -1 without one
+	started bool
+	lock    *sync.Mutex
+}
+
+func newOffsetTracker(channel amqp.Acknowledger, stream *streamState) *offsetTracker {
+	return &offsetTracker{
+		channel: channel,
+		stream:  stream,
+		offsets: make(map[uint64]int64),
+		settled: make(map[uint64]bool),
+		last:    -1,
+		lock:    &sync.Mutex{},
+	}
+}
+
+// Track records the offset of a delivery before it's handed out.
+func (ot *offsetTracker) track(delivery *amqp.Delivery) {
+	ot.lock.Lock()
+	defer ot.lock.Unlock()
+
+	if !ot.started { // a pooled channel doesn't start its delivery tags at 1
+		ot.floor = delivery.DeliveryTag - 1
+		ot.started = true
+	}
+
+	offset := int64(-1)
+	if value, ok := headerInt(delivery.Headers[models.StreamOffsetHeader]); ok {
+		offset = value
+	}
+
+	ot.offsets[delivery.DeliveryTag] = offset
+	if offset > ot.last {
+		ot.last = offset
+	}
+}
+
+// LastOffset gets the last offset received on the channel, where to resume consuming it. False on a nil tracker.
+func (ot *offsetTracker) lastOffset() (int64, bool) {
+	if ot == nil {
+		return 0, false
+	}
+
+	ot.lock.Lock()
+	defer ot.lock.Unlock()
+
+	return ot.last, ot.last >= 0
+}
+
+// Ack acknowledges the delivery then settles it.
+func (ot *offsetTracker) Ack(tag uint64, multiple bool) error {
+	if err := ot.channel.Ack(tag, multiple); err != nil {
+		return err
+	}
+
+	ot.settle(tag, multiple)
+	return nil
+}
+
+// Nack nacks the delivery then settles it.
+func (ot *offsetTracker) Nack(tag uint64, multiple bool, requeue bool) error {
+	if err := ot.channel.Nack(tag, multiple, requeue); err != nil {
+		return err
+	}
+
+	ot.settle(tag, multiple)
+	return nil
+}
+
+// Reject rejects the delivery then settles it.
+func (ot *offsetTracker) Reject(tag uint64, requeue bool) error {
+	if err := ot.channel.Reject(tag, requeue); err != nil {
+		return err
+	}
+
+	ot.settle(tag, false)
+	return nil
+}
+
+func (ot *offsetTracker) settle(tag uint64, multiple bool) {
+	ot.lock.Lock()
+	defer ot.lock.Unlock()
+
+	if multiple {
+		for trackedTag := range ot.offsets {
+			if trackedTag <= tag {
+				ot.settled[trackedTag] = true
+			}
+		}
+	} else {
+		ot.settled[tag] = true
+	}
+
+	for ot.settled[ot.floor+1] {
+		next := ot.floor + 1
+		if offset := ot.offsets[next]; offset >= 0 {
+			ot.stream.process(offset)
+		}
+
+		delete(ot.offsets, next)
+		delete(ot.settled, next)
+		ot.floor = next
+	}
+}
@@ -0,0 +1,107 @@
+package consumer
+
+import (
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+)
+
+func TestOffsetTrackerSettle(t *testing.T) {
+
+	type settlement struct {
+		tag      uint64
+		multiple bool
+	}
+
+	tests := []struct {
+		name      string
+		firstTag  uint64
+		offsets   []interface{} // x-stream-offset of each delivery, nil without one
+		settled   []settlement
+		processed int64
+		ok        bool
+		floor     uint64
+	}{
+		{
+			name:      "in order",
+			firstTag:  1,
+			offsets:   []interface{}{int64(10), int64(11), int64(12)},
+			settled:   []settlement{{1, false}, {2, false}},
+			processed: 11,
+			ok:        true,
+			floor:     2,
+		},
+		{
+			name:     "out of order waits on the first",
+			firstTag: 1,
+			offsets:  []interface{}{int64(10), int64(11), int64(12)},
+			settled:  []settlement{{3, false}, {2, false}},
+			floor:    0,
+		},
+		{
+			name:      "out of order completes the run",
+			firstTag:  1,
+			offsets:   []interface{}{int64(10), int64(11), int64(12)},
+			settled:   []settlement{{3, false}, {2, false}, {1, false}},
+			processed: 12,
+			ok:        true,
+			floor:     3,
+		},
+		{
+			name:      "pooled channel starting past 1",
+			firstTag:  41,
+			offsets:   []interface{}{int64(10), int64(11)},
+			settled:   []settlement{{41, false}},
+			processed: 10,
+			ok:        true,
+			floor:     41,
+		},
+		{
+			name:      "multiple",
+			firstTag:  1,
+			offsets:   []interface{}{int64(10), int64(11), int64(12)},
+			settled:   []settlement{{2, true}},
+			processed: 11,
+			ok:        true,
+			floor:     2,
+		},
+		{
+			name:      "deliveries without an offset",
+			firstTag:  1,
+			offsets:   []interface{}{int64(10), nil, int32(12)},
+			settled:   []settlement{{1, false}, {2, false}, {3, false}},
+			processed: 12,
+			ok:        true,
+			floor:     3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stream, err := newStreamState(&models.StreamConfig{})
+			assert.NoError(t, err)
+
+			tracker := newOffsetTracker(nil, stream)
+
+			for i, offset := range test.offsets {
+				delivery := &amqp.Delivery{DeliveryTag: test.firstTag + uint64(i), Headers: amqp.Table{}}
+				if offset != nil {
+					delivery.Headers[models.StreamOffsetHeader] = offset
+				}
+
+				tracker.track(delivery)
+			}
+
+			for _, settlement := range test.settled {
+				tracker.settle(settlement.tag, settlement.multiple)
+			}
+
+			assert.Equal(t, test.ok, stream.hasProcessed)
+			assert.Equal(t, test.processed, stream.processed)
+			assert.Equal(t, test.floor, tracker.floor)
+		})
+	}
+}
@@ -21,27 +21,44 @@ type PoolConfig struct {
 	ConnectionPoolConfig *ConnectionPoolConfig `json:"ConnectionPoolConfig"`
 }
 
+// OverflowPolicy decides what happens to a notification or error when its buffer is full.
+type OverflowPolicy string
+
+const (
+	// OverflowDropOldest makes room by dropping the oldest buffered item (the default).
+	OverflowDropOldest OverflowPolicy = "DropOldest"
+	// OverflowDropNewest drops the item that didn't fit.
+	OverflowDropNewest OverflowPolicy = "DropNewest"
+	// OverflowBlock waits for room in the buffer so nothing is lost, whatever raised the item waits with it. Not for
+	// the pools, their errors are raised while getting a connection or a channel.
+	OverflowBlock OverflowPolicy = "Block"
+	// OverflowCallback hands the item that didn't fit to the overflow handler (dropped if there isn't one).
+	OverflowCallback OverflowPolicy = "Callback"
+)
+
 // ChannelPoolConfig represents settings for creating channel pools.
 type ChannelPoolConfig struct {
-	ErrorBuffer          uint16 `json:"ErrorBuffer"`
-	SleepOnErrorInterval uint32 `json:"SleepOnErrorInterval"` // sleep length on errors
-	MaxChannelCount      uint64 `json:"MaxChannelCount"`
-	MaxAckChannelCount   uint64 `json:"MaxAckChannelCount"`
-	AckNoWait            bool   `json:"AckNoWait"`
-	GlobalQosCount       int    `json:"GlobalQosCount"` // Leave at 0 if you want to ignore them.
+	ErrorBuffer          uint16         `json:"ErrorBuffer"`
+	ErrorOverflowPolicy  OverflowPolicy `json:"ErrorOverflowPolicy"`  // empty is DropOldest, can't be Block
+	SleepOnErrorInterval uint32         `json:"SleepOnErrorInterval"` // sleep length on errors
+	MaxChannelCount      uint64         `json:"MaxChannelCount"`
+	MaxAckChannelCount   uint64         `json:"MaxAckChannelCount"`
+	AckNoWait            bool           `json:"AckNoWait"`
+	GlobalQosCount       int            `json:"GlobalQosCount"` // Leave at 0 if you want to ignore them.
 }
 
 // ConnectionPoolConfig represents settings for creating connection pools.
 type ConnectionPoolConfig struct {
-	ConnectionName       string     `json:"ConnectionName"`
-	URI                  string     `json:"URI"`
-	Heartbeat            uint32     `json:"Heartbeat"`
-	ConnectionTimeout    uint32     `json:"ConnectionTimeout"`
-	ErrorBuffer          uint16     `json:"ErrorBuffer"`
-	SleepOnErrorInterval uint32     `json:"SleepOnErrorInterval"` // sleep length on errors
-	EnableTLS            bool       `json:"EnableTLS"`            // Use TLSConfig to create connections with AMQPS uri.
-	MaxConnectionCount   uint64     `json:"MaxConnectionCount"`   // number of connections to create in the pool
-	TLSConfig            *TLSConfig `json:"TLSConfig"`            // TLS settings for connection with AMQPS.
+	ConnectionName       string         `json:"ConnectionName"`
+	URI                  string         `json:"URI"`
+	Heartbeat            uint32         `json:"Heartbeat"`
+	ConnectionTimeout    uint32         `json:"ConnectionTimeout"`
+	ErrorBuffer          uint16         `json:"ErrorBuffer"`
+	ErrorOverflowPolicy  OverflowPolicy `json:"ErrorOverflowPolicy"`  // empty is DropOldest, can't be Block
+	SleepOnErrorInterval uint32         `json:"SleepOnErrorInterval"` // sleep length on errors
+	EnableTLS            bool           `json:"EnableTLS"`            // Use TLSConfig to create connections with AMQPS uri.
+	MaxConnectionCount   uint64         `json:"MaxConnectionCount"`   // number of connections to create in the pool
+	TLSConfig            *TLSConfig     `json:"TLSConfig"`            // TLS settings for connection with AMQPS.
 }
 
 // TLSConfig represents settings for configuring TLS.
@@ -63,18 +80,157 @@ type ConsumerConfig struct {
 	QosCountOverride     int                    `json:"QosCountOverride"` // if zero ignored
 	MessageBuffer        uint32                 `json:"MessageBuffer"`
 	ErrorBuffer          uint32                 `json:"ErrorBuffer"`
+	ErrorOverflowPolicy  OverflowPolicy         `json:"ErrorOverflowPolicy"`  // empty is DropOldest
 	SleepOnErrorInterval uint32                 `json:"SleepOnErrorInterval"` // sleep on error
-	SleepOnIdleInterval  uint32                 `json:"SleepOnIdleInterval"`  // sleep on idle
+	SleepOnIdleInterval  uint32                 `json:"SleepOnIdleInterval"`  // unused, consumers block until a delivery arrives
+	ReassemblyConfig     *ReassemblyConfig      `json:"ReassemblyConfig"`     // optional, nil delivers chunks as they are
+	HandlerConfig        *HandlerConfig         `json:"HandlerConfig"`        // optional, how Consumer.Handle settles messages
+	RetryConfig          *RetryConfig           `json:"RetryConfig"`          // optional, nil requeues messages immediately
+	AckBatchConfig       *AckBatchConfig        `json:"AckBatchConfig"`       // optional, nil acknowledges every message on its own
+	AdaptivePrefetch     *AdaptivePrefetch      `json:"AdaptivePrefetch"`     // optional, tunes the prefetch instead of QosCountOverride
+	QueueConfig          *Queue                 `json:"QueueConfig"`          // optional, declared every time the consumer subscribes (empty Name is QueueName)
+	StreamConfig         *StreamConfig          `json:"StreamConfig"`         // optional, consumes a stream queue from an offset
+	Priority             int32                  `json:"Priority"`             // x-priority, consumers with a higher priority get deliveries first, 0 is the default priority
+	CancelOnHAFailover   bool                   `json:"CancelOnHAFailover"`   // x-cancel-on-ha-failover, the consumer resubscribes when a mirrored queue fails over
+	SingleActiveConsumer bool                   `json:"SingleActiveConsumer"` // the queue has x-single-active-consumer (implied by such a QueueConfig), see Consumer.Active
+}
+
+// ReassemblyConfig represents settings for how a Consumer puts chunked letters back together. Chunks stay unacked
+// until their group completes, so a letter can't have more chunks than the QosCountOverride (MinPrefetch when
+// adaptive): keep ChunkSize large enough for the biggest letters.
+type ReassemblyConfig struct {
+	Timeout  uint32 `json:"Timeout"`  // milliseconds an incomplete chunk group is kept before its chunks are rejected (and a completed one remembered), can't be 0
+	MaxBytes uint64 `json:"MaxBytes"` // chunk bytes buffered across all incomplete groups, 0 is unlimited
+}
+
+// AdaptivePrefetch represents how a Consumer tunes its prefetch (basic.qos) while consuming. The prefetch grows while
+// handlers wait on deliveries and halves when messages pile up in the buffer. An adaptive consumer holds its channel
+// exclusively so the prefetch applies to the whole channel.
+type AdaptivePrefetch struct {
+	MinPrefetch   int    `json:"MinPrefetch"`   // 0 is 1
+	MaxPrefetch   int    `json:"MaxPrefetch"`   // required
+	Interval      uint32 `json:"Interval"`      // milliseconds between adjustments, 0 is 1000
+	MaxBufferTime uint32 `json:"MaxBufferTime"` // milliseconds of handler work allowed to wait in the buffer, 0 is 1000
+}
+
+// StreamConfig represents how a Consumer consumes a stream queue (x-queue-type stream). A stream consumer needs a
+// QosCountOverride and manual acks, it holds its channel exclusively to track the offset it processed up to.
+// Without a checkpoint it starts from the Offset, with one it resumes after the offset checkpointed.
+type StreamConfig struct {
+	Offset              string `json:"Offset"`              // first, last, next, an offset or an RFC3339 timestamp, empty is next
+	CheckpointDirectory string `json:"CheckpointDirectory"` // optional, checkpoints are kept in a FileCheckpointStore
+	CheckpointInterval  uint32 `json:"CheckpointInterval"`  // milliseconds between checkpoints, 0 is 1000
+}
+
+// AckBatchConfig represents how a Consumer coalesces acknowledgements into multiple acks. A batching consumer
+// holds its channel exclusively as a multiple ack settles every earlier delivery on the channel.
+type AckBatchConfig struct {
+	Size     uint32 `json:"Size"`     // acks waiting before flushing, 0 is 100
+	Interval uint32 `json:"Interval"` // milliseconds between flushes when the batch isn't full, 0 is 100
+}
+
+// RetryConfig represents the delayed retries of a Consumer's messages. Every delay is a retry tier, a message that
+// fails once more after the last tier is parked. Consumer.Handle retries instead of requeueing when it is set.
+type RetryConfig struct {
+	Delays []uint32 `json:"Delays"` // milliseconds before each retry, e.g. [1000, 10000, 60000]
+}
+
+// HandlerConfig represents how Consumer.Handle settles messages.
+type HandlerConfig struct {
+	Timeout        uint32  `json:"Timeout"`        // milliseconds a handler gets per message, 0 is no timeout
+	ErrorOutcome   Outcome `json:"ErrorOutcome"`   // errors not marked Retryable or Permanent, empty is Reject
+	TimeoutOutcome Outcome `json:"TimeoutOutcome"` // empty is Requeue
+	PanicOutcome   Outcome `json:"PanicOutcome"`   // empty is Reject
+	WorkerCount    uint32  `json:"WorkerCount"`    // handlers running in parallel, capped by QosCountOverride, 0 is 1
 }
 
+// Outcome decides how Consumer.Handle settles a message once its handler returns.
+type Outcome string
+
+const (
+	// OutcomeAck acknowledges the message.
+	OutcomeAck Outcome = "Ack"
+	// OutcomeRequeue nacks the message back onto its queue, or retries it later when the Consumer has a RetryConfig.
+	OutcomeRequeue Outcome = "Requeue"
+	// OutcomeReject rejects the message without requeueing, it is dead-lettered when the queue has a DLX.
+	OutcomeReject Outcome = "Reject"
+)
+
 // PublisherConfig represents settings for configuring global settings for all Publishers with ease.
 type PublisherConfig struct {
-	SleepOnIdleInterval      uint32 `json:"SleepOnIdleInterval"`
-	SleepOnQueueFullInterval uint32 `json:"SleepOnQueueFullInterval"`
-	SleepOnErrorInterval     uint32 `json:"SleepOnErrorInterval"`
-	LetterBuffer             uint64 `json:"LetterBuffer"`
-	MaxOverBuffer            uint64 `json:"MaxOverBuffer"`
-	NotificationBuffer       uint32 `json:"NotificationBuffer"`
+	SleepOnIdleInterval        uint32             `json:"SleepOnIdleInterval"`      // unused, AutoPublish workers block until a letter is queued
+	SleepOnQueueFullInterval   uint32             `json:"SleepOnQueueFullInterval"` // unused, QueueLetter blocks until there is room
+	SleepOnErrorInterval       uint32             `json:"SleepOnErrorInterval"`
+	LetterBuffer               uint64             `json:"LetterBuffer"`
+	MaxOverBuffer              uint64             `json:"MaxOverBuffer"`
+	NotificationBuffer         uint32             `json:"NotificationBuffer"`
+	NotificationOverflowPolicy OverflowPolicy     `json:"NotificationOverflowPolicy"` // empty is DropOldest, Block loses nothing but the buffer has to be read
+	RateLimitConfig            *RateLimitConfig   `json:"RateLimitConfig"`            // optional, nil means unlimited
+	OrderedAutoPublish         bool               `json:"OrderedAutoPublish"`         // letters sharing an ordering key are published one at a time, in order
+	PublishWorkerCount         uint32             `json:"PublishWorkerCount"`         // AutoPublish workers, each holds a channel so kept under MaxChannelCount, 0 is one per CPU up to half the channels
+	MessageIDFormat            MessageIDFormat    `json:"MessageIDFormat"`            // how letters without a MessageID get one, empty is UUID
+	DedupeWindow               uint32             `json:"DedupeWindow"`               // milliseconds a MessageID is remembered to suppress duplicates, 0 disables
+	DelayConfig                *DelayConfig       `json:"DelayConfig"`                // optional, nil schedules every delayed letter in-process
+	FailureSinkConfig          *FailureSinkConfig `json:"FailureSinkConfig"`          // optional, where letters that run out of attempts go
+	ChunkSize                  uint64             `json:"ChunkSize"`                  // bytes, larger bodies are published as chunks, 0 disables
+	ClaimCheckThreshold        uint64             `json:"ClaimCheckThreshold"`        // bytes, larger bodies go to the BlobStore (SetBlobStore), 0 disables
+}
+
+// FailureSinkConfig represents where a Publisher keeps letters that ran out of publish attempts.
+// Letters are republished to the Exchange when set, the FilePath is used when there's no Exchange or that fails.
+type FailureSinkConfig struct {
+	Exchange   string `json:"Exchange"`   // failed letters exchange, empty skips republishing
+	RoutingKey string `json:"RoutingKey"` // empty keeps the letter's RoutingKey
+	FilePath   string `json:"FilePath"`   // JSON lines file (one FailedLetterRecord per line), empty disables
+	Timeout    uint32 `json:"Timeout"`    // milliseconds to wait for a free channel to republish on before falling back to the file, 0 is 5000
+}
+
+// DelayStrategy is how the broker holds on to a delayed letter until it is due.
+type DelayStrategy string
+
+const (
+	// DelayExchangeHeader sets the x-delay header, the letter's Exchange must be an x-delayed-message exchange
+	// (rabbitmq_delayed_message_exchange plugin).
+	DelayExchangeHeader DelayStrategy = "DelayedExchange"
+	// DelayWaitQueue parks the letter in a wait queue whose TTL dead-letters it to the letter's Exchange/RoutingKey.
+	// Delays are rounded down to a WaitQueueTiers so there's one wait queue per tier and destination, the remainder is
+	// waited in-process first. Unused wait queues expire.
+	DelayWaitQueue DelayStrategy = "WaitQueue"
+)
+
+// DelayConfig represents settings for how a Publisher delays letters (PublishAt/PublishAfter).
+type DelayConfig struct {
+	Strategy        DelayStrategy `json:"Strategy"`
+	LocalThreshold  uint32        `json:"LocalThreshold"`  // milliseconds, delays this short (or shorter) are scheduled in-process
+	WaitQueuePrefix string        `json:"WaitQueuePrefix"` // WaitQueue names start with this, empty is "turbocookedrabbit.wait"
+	WaitQueueTiers  []uint32      `json:"WaitQueueTiers"`  // milliseconds, ascending, empty is 1s, 5s, 10s, 30s, 1m, 5m, 10m, 30m and 1h
+}
+
+// MessageIDFormat is the kind of MessageID a Publisher generates for letters.
+type MessageIDFormat string
+
+const (
+	// MessageIDUUID generates random (version 4) UUIDs.
+	MessageIDUUID MessageIDFormat = "UUID"
+	// MessageIDULID generates ULIDs, which sort by creation time.
+	MessageIDULID MessageIDFormat = "ULID"
+)
+
+// RateLimitConfig represents token bucket settings for throttling how fast a Publisher sends letters.
+type RateLimitConfig struct {
+	Enabled          bool                    `json:"Enabled"`
+	GlobalRate       float64                 `json:"GlobalRate"`  // letters per second for every destination, 0 is unlimited
+	GlobalBurst      uint32                  `json:"GlobalBurst"` // letters allowed through at once, 0 is treated as 1
+	DestinationRates []*DestinationRateLimit `json:"DestinationRates"`
+}
+
+// DestinationRateLimit represents a token bucket for a single Exchange/RoutingKey pair.
+// Leaving RoutingKey empty applies the limit to every routing key on the Exchange.
+type DestinationRateLimit struct {
+	Exchange   string  `json:"Exchange"`
+	RoutingKey string  `json:"RoutingKey"`
+	Rate       float64 `json:"Rate"`  // letters per second
+	Burst      uint32  `json:"Burst"` // letters allowed through at once, 0 is treated as 1
 }
 
 // TopologyConfig allows you to build simple toplogies from a JSON file.
@@ -1,11 +1,48 @@
 package models
 
+import "time"
+
 // Letter contains the message body and address of where things are going.
 type Letter struct {
-	LetterID   uint64
-	RetryCount uint32
-	Body       []byte
-	Envelope   *Envelope
+	LetterID    uint64
+	MessageID   string // optional, assigned by the Publisher when empty and kept for every retry
+	RetryCount  uint32
+	Body        []byte
+	Envelope    *Envelope
+	Deadline    time.Time // optional, the zero value means the letter never expires
+	OrderingKey string    // optional, ordered AutoPublish falls back to the Envelope's RoutingKey
+}
+
+// SetTTL sets the letter's Deadline to ttl from now.
+func (letter *Letter) SetTTL(ttl time.Duration) {
+	letter.Deadline = time.Now().Add(ttl)
+}
+
+// Expired lets you know if the letter has a Deadline and it has passed.
+func (letter *Letter) Expired() bool {
+	return !letter.Deadline.IsZero() && !time.Now().Before(letter.Deadline)
+}
+
+// TimeRemaining lets you know how long the letter has until its Deadline (zero when expired or no Deadline).
+func (letter *Letter) TimeRemaining() time.Duration {
+	if letter.Deadline.IsZero() {
+		return 0
+	}
+
+	if remaining := time.Until(letter.Deadline); remaining > 0 {
+		return remaining
+	}
+
+	return 0
+}
+
+// GetOrderingKey gets the key used to keep letters in order during ordered AutoPublish.
+func (letter *Letter) GetOrderingKey() string {
+	if letter.OrderingKey != "" {
+		return letter.OrderingKey
+	}
+
+	return letter.Envelope.RoutingKey
 }
 
 // Envelope contains all the address details of where a letter is going.
@@ -19,6 +56,17 @@ type Envelope struct {
 	DeliveryMode uint8
 }
 
+// FailedLetterRecord is a letter that ran out of publish attempts, as written to a failure sink file.
+type FailedLetterRecord struct {
+	LetterID   uint64                 `json:"LetterID"`
+	MessageID  string                 `json:"MessageID"`
+	Exchange   string                 `json:"Exchange"`
+	RoutingKey string                 `json:"RoutingKey"`
+	Headers    map[string]interface{} `json:"Headers,omitempty"` // includes the x-failure headers
+	Body       []byte                 `json:"Body"`
+	FailedAt   time.Time              `json:"FailedAt"`
+}
+
 // ModdedLetter is a letter with a modified body and indicators of what was done to it.
 type ModdedLetter struct {
 	LetterID       uint64      `json:"LetterID"`
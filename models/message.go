@@ -8,11 +8,54 @@ import (
 	"github.com/streadway/amqp"
 )
 
+// FailureReason describes why a Notification reports a failure.
+type FailureReason uint8
+
+const (
+	// FailureNone is the reason on successful Notifications.
+	FailureNone FailureReason = iota
+	// FailurePublish means the letter could not be published (channel or server errors).
+	FailurePublish
+	// FailureExpired means the letter's Deadline passed before it could be published.
+	FailureExpired
+	// FailureDuplicate means a letter with the same MessageID was submitted within the Publisher's dedupe window.
+	FailureDuplicate
+	// FailureRejected means a BeforePublish hook rejected the letter.
+	FailureRejected
+	// FailureNacked means the server nacked the letter's publisher confirm.
+	FailureNacked
+	// FailureReturned means the server returned the mandatory or immediate letter as unroutable.
+	FailureReturned
+)
+
+// String gets the name of the FailureReason.
+func (reason FailureReason) String() string {
+	switch reason {
+	case FailureNone:
+		return "None"
+	case FailurePublish:
+		return "Publish"
+	case FailureExpired:
+		return "Expired"
+	case FailureDuplicate:
+		return "Duplicate"
+	case FailureRejected:
+		return "Rejected"
+	case FailureNacked:
+		return "Nacked"
+	case FailureReturned:
+		return "Returned"
+	}
+
+	return fmt.Sprintf("FailureReason(%d)", uint8(reason))
+}
+
 // Notification is a way to communicate between callers
 type Notification struct {
 	LetterID     uint64
 	FailedLetter *Letter
 	Success      bool
+	Reason       FailureReason
 	Error        error
 }
 
@@ -25,13 +68,49 @@ func (not *Notification) ToString() string {
 	return fmt.Sprintf("[LetterID: %d] - Failed.\r\nError: %s\r\n", not.LetterID, not.Error.Error())
 }
 
+// Headers describing a chunk of a letter that was too large to publish as a single message.
+const (
+	ChunkGroupHeader = "x-chunk-group" // the letter's MessageID, shared by all its chunks
+	ChunkSeqHeader   = "x-chunk-seq"   // the chunk's position, starting at 0
+	ChunkTotalHeader = "x-chunk-total" // how many chunks make up the letter
+)
+
+// RetryAttemptHeader holds how many times a message was retried through the Consumer's retry queues.
+const RetryAttemptHeader = "x-retry-attempt"
+
+// StreamOffsetHeader holds the offset of a message delivered from a stream queue.
+const StreamOffsetHeader = "x-stream-offset"
+
+// ClaimCheckHeader holds the BlobStore key of a letter's Body when it was too large to publish (claim-check).
+const ClaimCheckHeader = "x-claim-check"
+
 // Message allow for you to acknowledge, after processing the payload, by its RabbitMQ tag and Channel pointer.
 type Message struct {
-	IsAckable   bool
-	Headers     map[string]interface{}
-	Body        []byte
-	deliveryTag uint64
-	amqpChan    *amqp.Channel
+	IsAckable bool
+	Headers   map[string]interface{}
+	Body      []byte
+
+	// Delivery
+	Exchange    string // basic.publish exchange
+	RoutingKey  string // basic.publish routing key
+	Redelivered bool   // delivered before and not acknowledged
+	ConsumerTag string // consumer the message was delivered to, empty for Get
+
+	// Properties
+	ContentType     string    // MIME content type
+	ContentEncoding string    // MIME content encoding
+	DeliveryMode    uint8     // queue implementation use - non-persistent (1) or persistent (2)
+	MessageID       string    // application use - message identifier
+	CorrelationID   string    // application use - correlation identifier
+	ReplyTo         string    // application use - address to to reply to (ex: RPC)
+	Timestamp       time.Time // application use - message timestamp
+	Priority        uint8     // queue implementation use - 0 to 9
+	Type            string    // application use - message type name
+	AppID           string    // application use - creating application
+
+	deliveryTag  uint64
+	acknowledger amqp.Acknowledger
+	chunks       []*Message
 }
 
 // NewMessage creates a new Message.
@@ -42,56 +121,145 @@ func NewMessage(
 	deliveryTag uint64,
 	amqpChan *amqp.Channel) *Message {
 
-	return &Message{
+	msg := &Message{
 		IsAckable:   isAckable,
 		Headers:     headers,
 		Body:        body,
 		deliveryTag: deliveryTag,
-		amqpChan:    amqpChan,
 	}
+
+	if amqpChan != nil {
+		msg.acknowledger = amqpChan
+	}
+
+	return msg
+}
+
+// NewMessageFromDelivery creates a new Message with the body, headers and metadata of an amqp.Delivery.
+// The Message is settled through the acknowledger, usually the channel it was delivered on.
+func NewMessageFromDelivery(isAckable bool, delivery *amqp.Delivery, acknowledger amqp.Acknowledger) *Message {
+
+	return &Message{
+		IsAckable:       isAckable,
+		Headers:         delivery.Headers,
+		Body:            delivery.Body,
+		Exchange:        delivery.Exchange,
+		RoutingKey:      delivery.RoutingKey,
+		Redelivered:     delivery.Redelivered,
+		ConsumerTag:     delivery.ConsumerTag,
+		ContentType:     delivery.ContentType,
+		ContentEncoding: delivery.ContentEncoding,
+		DeliveryMode:    delivery.DeliveryMode,
+		MessageID:       delivery.MessageId,
+		CorrelationID:   delivery.CorrelationId,
+		ReplyTo:         delivery.ReplyTo,
+		Timestamp:       delivery.Timestamp,
+		Priority:        delivery.Priority,
+		Type:            delivery.Type,
+		AppID:           delivery.AppId,
+		deliveryTag:     delivery.DeliveryTag,
+		acknowledger:    acknowledger,
+	}
+}
+
+// ClaimCheckKey gets the BlobStore key the Message's Body was fetched from, false when it wasn't claim-checked.
+func (msg *Message) ClaimCheckKey() (string, bool) {
+	key, ok := msg.Headers[ClaimCheckHeader].(string)
+	return key, ok && key != ""
+}
+
+// NewReassembledMessage creates a Message out of the chunks of a letter, acknowledging it acknowledges every chunk.
+func NewReassembledMessage(headers map[string]interface{}, body []byte, chunks []*Message) *Message {
+
+	msg := &Message{}
+	if len(chunks) > 0 {
+		*msg = *chunks[0] // delivery metadata of the first chunk
+	}
+
+	msg.IsAckable = len(chunks) > 0
+	for _, chunk := range chunks {
+		msg.IsAckable = msg.IsAckable && chunk.IsAckable
+	}
+
+	msg.Headers = headers
+	msg.Body = body
+	msg.deliveryTag = 0
+	msg.acknowledger = nil
+	msg.chunks = chunks
+
+	return msg
+}
+
+// Chunks gets the chunks a reassembled Message was put together from, duplicates included. Nil for other Messages.
+func (msg *Message) Chunks() []*Message {
+	return msg.chunks
 }
 
 // Acknowledge allows for you to acknowledge message on the original channel it was received.
 // Will fail if channel is closed and this is by design per RabbitMQ server.
 // Can't ack from a different channel.
 func (msg *Message) Acknowledge() error {
+	if msg.chunks != nil {
+		return msg.eachChunk((*Message).Acknowledge)
+	}
+
 	if !msg.IsAckable {
 		return errors.New("can't acknowledge, not an ackable message")
 	}
 
-	if msg.amqpChan == nil {
+	if msg.acknowledger == nil {
 		return errors.New("can't acknowledge, internal channel is nil")
 	}
 
-	return msg.amqpChan.Ack(msg.deliveryTag, false)
+	return msg.acknowledger.Ack(msg.deliveryTag, false)
 }
 
 // Nack allows for you to negative acknowledge message on the original channel it was received.
 // Will fail if channel is closed and this is by design per RabbitMQ server.
 func (msg *Message) Nack(requeue bool) error {
+	if msg.chunks != nil {
+		return msg.eachChunk(func(chunk *Message) error { return chunk.Nack(requeue) })
+	}
+
 	if !msg.IsAckable {
 		return errors.New("can't nack, not an ackable message")
 	}
 
-	if msg.amqpChan == nil {
+	if msg.acknowledger == nil {
 		return errors.New("can't nack, internal channel is nil")
 	}
 
-	return msg.amqpChan.Nack(msg.deliveryTag, false, requeue)
+	return msg.acknowledger.Nack(msg.deliveryTag, false, requeue)
 }
 
 // Reject allows for you to reject on the original channel it was received.
 // Will fail if channel is closed and this is by design per RabbitMQ server.
 func (msg *Message) Reject(requeue bool) error {
+	if msg.chunks != nil {
+		return msg.eachChunk(func(chunk *Message) error { return chunk.Reject(requeue) })
+	}
+
 	if !msg.IsAckable {
 		return errors.New("can't reject, not an ackable message")
 	}
 
-	if msg.amqpChan == nil {
+	if msg.acknowledger == nil {
 		return errors.New("can't reject, internal channel is nil")
 	}
 
-	return msg.amqpChan.Reject(msg.deliveryTag, requeue)
+	return msg.acknowledger.Reject(msg.deliveryTag, requeue)
+}
+
+// EachChunk calls action on every chunk of a reassembled Message, returning the first error.
+func (msg *Message) eachChunk(action func(*Message) error) error {
+	var firstErr error
+	for _, chunk := range msg.chunks {
+		if err := action(chunk); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
 }
 
 // ErrorMessage allow for you to replay a message that was returned.
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// WorkerStats is a snapshot of how busy a Publisher's AutoPublish workers are.
+type WorkerStats struct {
+	WorkerCount    uint32  `json:"WorkerCount"`    // workers running, 0 when AutoPublish is stopped
+	BusyWorkers    uint32  `json:"BusyWorkers"`    // workers currently publishing a letter
+	QueuedLetters  uint64  `json:"QueuedLetters"`  // letters queued or being published
+	Saturation     float64 `json:"Saturation"`     // BusyWorkers / WorkerCount
+	SaturatedQueue uint64  `json:"SaturatedQueue"` // letters queued while every worker was busy
+}
+
+// PublisherStats is a snapshot of a Publisher's counters and latencies since it was created.
+type PublisherStats struct {
+	Published       uint64            `json:"Published"`       // letters published
+	Failed          uint64            `json:"Failed"`          // letters that ran out of attempts or were rejected
+	Retried         uint64            `json:"Retried"`         // publish attempts after the first one
+	Queued          uint64            `json:"Queued"`          // letters queued for AutoPublish
+	Dropped         uint64            `json:"Dropped"`         // letters dropped before publishing (expired or duplicate)
+	QueueWait       *LatencyHistogram `json:"QueueWait"`       // queued until an AutoPublish worker picks the letter up
+	ChannelCheckout *LatencyHistogram `json:"ChannelCheckout"` // getting a channel from the ChannelPool
+	Publish         *LatencyHistogram `json:"Publish"`         // a single publish attempt
+	Confirm         *LatencyHistogram `json:"Confirm"`         // published until confirmed by the server (confirm mode only)
+}
+
+// ConsumerStats is a snapshot of a Consumer's counters since it was created.
+type ConsumerStats struct {
+	Received       uint64        `json:"Received"`       // deliveries received
+	Handled        uint64        `json:"Handled"`        // messages handled by Consumer.Handle
+	HandlerLatency time.Duration `json:"HandlerLatency"` // average time a handler took
+	Buffered       int           `json:"Buffered"`       // messages waiting in the Messages buffer
+	Prefetch       int           `json:"Prefetch"`       // current prefetch (basic.qos), 0 when not set
+	Paused         bool          `json:"Paused"`         // not receiving new deliveries (Consumer.Pause)
+	Active         bool          `json:"Active"`         // subscribed and getting deliveries (Consumer.Active)
+}
+
+// LatencyHistogram is a snapshot of how long something took.
+type LatencyHistogram struct {
+	Count   uint64           `json:"Count"`
+	Sum     time.Duration    `json:"Sum"`
+	Max     time.Duration    `json:"Max"`
+	Buckets []*LatencyBucket `json:"Buckets"` // cumulative, the last bucket has no UpperBound (+Inf)
+}
+
+// LatencyBucket counts observations up to and including its UpperBound.
+type LatencyBucket struct {
+	UpperBound time.Duration `json:"UpperBound"` // zero for the +Inf bucket
+	Count      uint64        `json:"Count"`
+}
+
+// Mean is the average latency observed.
+func (histogram *LatencyHistogram) Mean() time.Duration {
+	if histogram.Count == 0 {
+		return 0
+	}
+
+	return histogram.Sum / time.Duration(histogram.Count)
+}
@@ -2,6 +2,7 @@ package pools
 
 import (
 	"errors"
+	"sync"
 
 	"github.com/prom3t3us/turbocookedrabbit/models"
 	"github.com/streadway/amqp"
@@ -17,6 +18,8 @@ type ChannelHost struct {
 	ReturnMessages chan *models.ReturnMessage
 	closeErrors    chan *amqp.Error
 	returnMessages chan amqp.Return
+	cancelWatchers map[string]chan string
+	cancelLock     *sync.Mutex
 }
 
 // NewChannelHost creates a simple ConnectionHost wrapper for management by end-user developer.
@@ -41,14 +44,20 @@ func NewChannelHost(
 		ConnectionID:   connectionID,
 		ackable:        ackable,
 		ErrorMessages:  make(chan *models.ErrorMessage, 1),
-		ReturnMessages: make(chan *models.ReturnMessage, 1),
+		ReturnMessages: make(chan *models.ReturnMessage, 1024),
 		closeErrors:    make(chan *amqp.Error, 1),
-		returnMessages: make(chan amqp.Return, 1),
+		returnMessages: make(chan amqp.Return, 1024), // sized like the confirms buffer, a full one stalls the connection
+		cancelWatchers: make(map[string]chan string),
+		cancelLock:     &sync.Mutex{},
 	}
 
 	channelHost.Channel.NotifyClose(channelHost.closeErrors)
 	channelHost.Channel.NotifyReturn(channelHost.returnMessages)
 
+	// A cancel listener can't be removed and blocks the channel when it isn't read, one per channel is drained for
+	// as long as the channel is open and hands each cancellation to whoever watches its consumer tag.
+	go channelHost.dispatchCancellations(channelHost.Channel.NotifyCancel(make(chan string, 1)))
+
 	return channelHost, nil
 }
 
@@ -79,6 +88,40 @@ func (ch *ChannelHost) Returns() <-chan *models.ReturnMessage {
 	return ch.ReturnMessages
 }
 
+// WatchCancellation gets where the server's cancellation (basic.cancel) of the consumerTag's subscription arrives,
+// until StopWatchingCancellation. Only the first cancellation is kept until it is read.
+func (ch *ChannelHost) WatchCancellation(consumerTag string) <-chan string {
+	ch.cancelLock.Lock()
+	defer ch.cancelLock.Unlock()
+
+	cancellations := make(chan string, 1)
+	ch.cancelWatchers[consumerTag] = cancellations
+
+	return cancellations
+}
+
+// StopWatchingCancellation stops watching the cancellation of the consumerTag's subscription.
+func (ch *ChannelHost) StopWatchingCancellation(consumerTag string) {
+	ch.cancelLock.Lock()
+	defer ch.cancelLock.Unlock()
+
+	delete(ch.cancelWatchers, consumerTag)
+}
+
+// DispatchCancellations hands the cancellations to their watchers until the channel closes.
+func (ch *ChannelHost) dispatchCancellations(notifications <-chan string) {
+	for consumerTag := range notifications {
+		ch.cancelLock.Lock()
+		if cancellations, ok := ch.cancelWatchers[consumerTag]; ok {
+			select {
+			case cancellations <- consumerTag:
+			default:
+			}
+		}
+		ch.cancelLock.Unlock()
+	}
+}
+
 // IsAckable determines if this host contains an ackable channel.
 func (ch *ChannelHost) IsAckable() bool {
 	return ch.ackable
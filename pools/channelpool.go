@@ -9,6 +9,7 @@ import (
 	"github.com/Workiva/go-datastructures/queue"
 
 	"github.com/prom3t3us/turbocookedrabbit/models"
+	"github.com/prom3t3us/turbocookedrabbit/utils"
 )
 
 // TODO: Investigate the value of Sync.Map instead of map + lock for FlaggedChannels.
@@ -19,6 +20,7 @@ type ChannelPool struct {
 	connectionPool       *ConnectionPool
 	Initialized          bool
 	errors               chan error
+	errorDispatch        *utils.Dispatcher
 	channels             *queue.Queue
 	ackChannels          *queue.Queue
 	maxChannels          uint64
@@ -43,6 +45,10 @@ func NewChannelPool(
 		return nil, errors.New("channelpool maxchannelcount or maxackchannelcount can't be 0")
 	}
 
+	if config.ChannelPoolConfig.ErrorOverflowPolicy == models.OverflowBlock { // errors are raised inside GetChannel
+		return nil, errors.New("can't create a ChannelPool blocking on a full ErrorBuffer, GetChannel would hang")
+	}
+
 	if connPool == nil {
 		var err error // If connPool is nil, create one here.
 		connPool, err = NewConnectionPool(config, initializeNow)
@@ -66,6 +72,7 @@ func NewChannelPool(
 		globalQosCount:       config.ChannelPoolConfig.GlobalQosCount,
 		ackNoWait:            config.ChannelPoolConfig.AckNoWait,
 	}
+	cp.errorDispatch = utils.NewErrorDispatcher(cp.errors, config.ChannelPoolConfig.ErrorOverflowPolicy)
 
 	if initializeNow {
 		if err := cp.Initialize(); err != nil {
@@ -192,7 +199,7 @@ GetNewConnection:
 }
 
 func (cp *ChannelPool) handleError(err error) {
-	go func() { cp.errors <- err }()
+	cp.errorDispatch.Dispatch(err)
 }
 
 // Errors yields all the internal err chan for managing the ChannelPool.
@@ -200,6 +207,11 @@ func (cp *ChannelPool) Errors() <-chan error {
 	return cp.errors
 }
 
+// ErrorDispatcher gets the Dispatcher behind Errors.
+func (cp *ChannelPool) ErrorDispatcher() *utils.Dispatcher {
+	return cp.errorDispatch
+}
+
 // GetChannel gets a channel based on whats ChannelPool queue (blocking under bad network conditions).
 // Outages/transient network outages block until success connecting.
 // Uses the SleepOnErrorInterval to pause between retries.
@@ -263,6 +275,46 @@ DequeueChannel:
 	return channelHost, nil
 }
 
+// TryGetChannel gets a channel like GetChannel but waits no longer than the timeout for one and never reconnects.
+// A closed or flagged channel is returned to the pool (flagged) with an error, so callers can't hang during outages.
+func (cp *ChannelPool) TryGetChannel(timeout time.Duration) (*ChannelHost, error) {
+	if atomic.LoadInt32(&cp.channelLock) > 0 {
+		return nil, errors.New("can't get channel - channel pool has been shutdown")
+	}
+
+	if !cp.Initialized {
+		return nil, errors.New("can't get channel - channel pool has not been initialized")
+	}
+
+	if timeout <= 0 {
+		timeout = time.Nanosecond // Poll waits forever without a timeout
+	}
+
+	structs, err := cp.channels.Poll(1, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	channelHost, ok := structs[0].(*ChannelHost)
+	if !ok {
+		return nil, errors.New("invalid struct type found in ChannelPool queue")
+	}
+
+	select {
+	case <-channelHost.CloseErrors():
+		cp.FlagChannel(channelHost.ChannelID)
+	default:
+		break
+	}
+
+	if cp.IsChannelFlagged(channelHost.ChannelID) {
+		cp.ReturnChannel(channelHost, true)
+		return nil, errors.New("can't get channel - the channel is closed")
+	}
+
+	return channelHost, nil
+}
+
 // ReturnChannel puts the connection back in the queue.
 // Developer has to manually return the Channel and helps maintain a Round Robin on Channels and their resources.
 // Optional parameter allows you to flag a Channel as dead.
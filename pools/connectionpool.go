@@ -23,6 +23,7 @@ type ConnectionPool struct {
 	enableTLS                  bool
 	tlsConfig                  *tls.Config
 	errors                     chan error
+	errorDispatch              *utils.Dispatcher
 	heartbeat                  time.Duration
 	connectionTimeout          time.Duration
 	connections                *queue.Queue
@@ -71,6 +72,10 @@ func NewConnectionPool(
 		return nil, errors.New("can't create a ConnectionPool when the ErrorBuffer value is 0")
 	}
 
+	if config.ConnectionPoolConfig.ErrorOverflowPolicy == models.OverflowBlock { // errors are raised inside GetConnection
+		return nil, errors.New("can't create a ConnectionPool blocking on a full ErrorBuffer, GetConnection would hang")
+	}
+
 	maxChannelPerConnection := uint64(1)
 	if config.ConnectionPoolConfig.MaxConnectionCount == 1 {
 		maxChannelPerConnection = config.ChannelPoolConfig.MaxChannelCount
@@ -103,6 +108,7 @@ func NewConnectionPool(
 		flaggedConnections:         make(map[uint64]bool),
 		sleepOnErrorInterval:       time.Duration(config.ConnectionPoolConfig.SleepOnErrorInterval) * time.Millisecond,
 	}
+	cp.errorDispatch = utils.NewErrorDispatcher(cp.errors, config.ConnectionPoolConfig.ErrorOverflowPolicy)
 
 	if initializeNow {
 		if err = cp.Initialize(); err != nil {
@@ -211,7 +217,7 @@ func (cp *ConnectionPool) createConnectionHostWithTLS(connectionID uint64) (*Con
 }
 
 func (cp *ConnectionPool) handleError(err error) {
-	go func() { cp.errors <- err }()
+	cp.errorDispatch.Dispatch(err)
 }
 
 // Errors yields all the internal errs for creating connections.
@@ -219,6 +225,11 @@ func (cp *ConnectionPool) Errors() <-chan error {
 	return cp.errors
 }
 
+// ErrorDispatcher gets the Dispatcher behind Errors, where failed connection attempts are reported.
+func (cp *ConnectionPool) ErrorDispatcher() *utils.Dispatcher {
+	return cp.errorDispatch
+}
+
 // GetConnection gets a connection based on whats in the ConnectionPool (blocking under bad network conditions).
 // Outages/transient network outages block until success connecting.
 // Uses the SleepOnErrorInterval to pause between retries.
@@ -0,0 +1,412 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+	"github.com/prom3t3us/turbocookedrabbit/pools"
+
+	"github.com/streadway/amqp"
+)
+
+// PublishFuture is the pending result of a letter published with PublishAsync.
+type PublishFuture struct {
+	letter       *models.Letter
+	outgoing     *models.Letter
+	start        time.Time
+	publishedAt  time.Time
+	duration     time.Duration // from PublishAsync to done, for the AfterPublish hooks
+	pending      uint64
+	reason       models.FailureReason
+	err          error
+	done         chan struct{}
+	notification *models.Notification
+}
+
+func newPublishFuture(letter *models.Letter) *PublishFuture {
+	return &PublishFuture{
+		letter: letter,
+		start:  time.Now(),
+		done:   make(chan struct{}),
+	}
+}
+
+// Letter gets the letter the future was created for.
+func (future *PublishFuture) Letter() *models.Letter {
+	return future.letter
+}
+
+// Done is closed once the letter has been confirmed, nacked, returned or failed to publish.
+func (future *PublishFuture) Done() <-chan struct{} {
+	return future.done
+}
+
+// Wait blocks until the future is done or ctx is done. Returns nil when the server confirmed the letter, the
+// Notification's Error when it didn't and ctx.Err() when ctx is done first.
+func (future *PublishFuture) Wait(ctx context.Context) error {
+	select {
+	case <-future.done:
+		return future.notification.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Notification gets the Notification sent for the letter, nil until the future is done.
+func (future *PublishFuture) Notification() *models.Notification {
+	select {
+	case <-future.done:
+		return future.notification
+	default:
+		return nil
+	}
+}
+
+func (future *PublishFuture) complete(notification *models.Notification) {
+	future.notification = notification
+	future.duration = time.Since(future.start)
+	close(future.done)
+}
+
+// AsyncPublisher owns the confirm mode channel PublishAsync publishes on.
+type asyncPublisher struct {
+	current *confirmChannel
+	lock    *sync.Mutex
+}
+
+func newAsyncPublisher() *asyncPublisher {
+	return &asyncPublisher{
+		lock: &sync.Mutex{},
+	}
+}
+
+// ConfirmChannel is a channel in confirm mode leased from the ChannelPool and the futures waiting on its confirms.
+// Replaced by a new one whenever the channel fails.
+type confirmChannel struct {
+	chanHost *pools.ChannelHost
+	confirms chan amqp.Confirmation
+	nextTag  uint64
+	pending  map[uint64]*PublishFuture
+	returned map[string]*models.ReturnMessage
+}
+
+// PublishAsync publishes the letter on a channel in confirm mode without waiting on the server. The PublishFuture
+// returned is done once the server confirmed, nacked or returned the letter, or it failed to publish. The result is
+// sent to Notifications (and the AfterPublish hooks) as well, after the future is done.
+// Goes through the same steps as Publish (MessageID, dedupe, rate limit, hooks, claim-check, chunking), a chunked
+// letter is done once every chunk is confirmed. PublishAsync doesn't retry, letters are not sent to the failure sink.
+func (pub *Publisher) PublishAsync(letter *models.Letter) *PublishFuture {
+
+	future := newPublishFuture(letter)
+
+	if notification := pub.admitLetter(letter); notification != nil {
+		future.complete(notification)
+		return future
+	}
+
+	if pub.rateLimiter != nil {
+		pub.rateLimiter.wait(letter)
+	}
+
+	if err := pub.middleware.runBefore(letter); err != nil {
+		pub.resolveFuture(future, models.FailureRejected, err)
+		return future
+	}
+
+	outgoing, err := pub.claimCheck(letter)
+	if err != nil {
+		pub.resolveFuture(future, models.FailurePublish, err)
+		return future
+	}
+
+	future.outgoing = outgoing
+
+	if letter.Expired() {
+		pub.releaseClaimCheck(letter, outgoing)
+		notification := pub.expireLetter(letter)
+		pub.middleware.runAfter(letter, notification.Error, time.Since(future.start))
+		future.complete(notification)
+		return future
+	}
+
+	if err = pub.async.publish(pub, future); err != nil {
+		pub.resolveFuture(future, models.FailurePublish, err)
+	}
+
+	return future
+}
+
+// ResolveFuture completes the future of an async letter, then reports it to Notifications and the AfterPublish hooks.
+func (pub *Publisher) resolveFuture(future *PublishFuture, reason models.FailureReason, err error) {
+	pub.completeFuture(future, reason, err)
+	pub.reportFuture(future)
+}
+
+// CompleteFuture settles the result of an async letter and completes its future without reporting it.
+func (pub *Publisher) completeFuture(future *PublishFuture, reason models.FailureReason, err error) {
+
+	notification := &models.Notification{
+		LetterID: future.letter.LetterID,
+		Success:  true,
+	}
+
+	if err == nil {
+		atomic.AddUint64(&pub.stats.published, 1)
+		pub.stats.confirm.observe(time.Since(future.publishedAt))
+	} else {
+		atomic.AddUint64(&pub.stats.failed, 1)
+		pub.releaseLetter(future.letter)
+		if future.outgoing != nil {
+			pub.releaseClaimCheck(future.letter, future.outgoing)
+		}
+
+		notification = &models.Notification{
+			LetterID:     future.letter.LetterID,
+			FailedLetter: future.letter,
+			Reason:       reason,
+			Error:        err,
+		}
+	}
+
+	future.complete(notification)
+}
+
+// ReportFuture sends the Notification of a completed future and runs the AfterPublish hooks, either can block.
+func (pub *Publisher) reportFuture(future *PublishFuture) {
+	pub.notificationDispatch.Dispatch(future.notification)
+	pub.middleware.runAfter(future.letter, future.notification.Error, future.duration)
+}
+
+// Publish publishes the future's letter on the current confirm channel, opening one when needed. The future's delivery
+// tags are registered before publishing so a confirm can't arrive before its future.
+func (ap *asyncPublisher) publish(pub *Publisher, future *PublishFuture) error {
+	ap.lock.Lock()
+
+	if ap.current == nil {
+		cc, err := ap.open(pub)
+		if err != nil {
+			ap.lock.Unlock()
+			return err
+		}
+
+		ap.current = cc
+	}
+
+	cc := ap.current
+	count := pub.chunkCount(future.outgoing)
+	for tag := cc.nextTag; tag < cc.nextTag+count; tag++ {
+		cc.pending[tag] = future
+	}
+
+	future.pending = count
+	future.publishedAt = time.Now()
+
+	err := pub.publishLetter(cc.chanHost.Channel, future.outgoing)
+	pub.stats.publish.observe(time.Since(future.publishedAt))
+	if err != nil {
+		for tag := cc.nextTag; tag < cc.nextTag+count; tag++ {
+			delete(cc.pending, tag)
+		}
+
+		// Delivery tags are only used up by what reached the server, the channel can't be trusted any more.
+		// Closing it fails the letters still waiting on it and returns it to the ChannelPool. Closed outside the lock
+		// as the listener needs it to drain the confirms.
+		ap.current = nil
+		ap.lock.Unlock()
+
+		_ = cc.chanHost.Channel.Close()
+		return err
+	}
+
+	cc.nextTag += count
+	ap.lock.Unlock()
+	return nil
+}
+
+// Open leases a channel from the ChannelPool, puts it in confirm mode and starts listening to its confirms.
+func (ap *asyncPublisher) open(pub *Publisher) (*confirmChannel, error) {
+
+	checkoutStart := time.Now()
+	chanHost, err := pub.ChannelPool.GetChannel()
+	pub.stats.channelCheckout.observe(time.Since(checkoutStart))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = chanHost.Channel.Confirm(false); err != nil {
+		pub.ChannelPool.FlagChannel(chanHost.ChannelID)
+		pub.ChannelPool.ReturnChannel(chanHost, false)
+		return nil, fmt.Errorf("can't put the channel in confirm mode: %s", err)
+	}
+
+	cc := &confirmChannel{
+		chanHost: chanHost,
+		confirms: chanHost.Channel.NotifyPublish(make(chan amqp.Confirmation, 1024)),
+		nextTag:  1,
+		pending:  make(map[uint64]*PublishFuture),
+		returned: make(map[string]*models.ReturnMessage),
+	}
+
+	go ap.listen(pub, cc)
+
+	return cc, nil
+}
+
+// Listen completes the futures of a confirm channel as their confirms arrive. Once the channel closes every future
+// still pending fails and the channel goes back to the ChannelPool, flagged so it's recreated outside confirm mode.
+// The futures are reported on another goroutine so a full Notifications buffer or a slow hook can't hold up the
+// confirms.
+func (ap *asyncPublisher) listen(pub *Publisher, cc *confirmChannel) {
+
+	reporter := newFutureReporter()
+	go reporter.run(pub)
+	defer reporter.close()
+
+	for confirmation := range cc.confirms {
+		ap.lock.Lock()
+		ap.collectReturns(cc)
+
+		future, ok := cc.pending[confirmation.DeliveryTag]
+		if !ok {
+			ap.lock.Unlock()
+			continue
+		}
+
+		delete(cc.pending, confirmation.DeliveryTag)
+		future.pending--
+		if !confirmation.Ack && future.err == nil {
+			future.reason = models.FailureNacked
+			future.err = errors.New("letter was nacked by the server")
+		}
+
+		if ret, ok := cc.returned[future.letter.MessageID]; ok && future.err == nil {
+			future.reason = models.FailureReturned
+			future.err = fmt.Errorf("letter was returned by the server: %d %s", ret.ReplyCode, ret.ReplyText)
+		}
+
+		done := future.pending == 0
+		if done {
+			delete(cc.returned, future.letter.MessageID)
+		}
+
+		ap.lock.Unlock()
+
+		if done {
+			pub.completeFuture(future, future.reason, future.err)
+			reporter.push(future)
+		}
+	}
+
+	ap.lock.Lock()
+	if ap.current == cc {
+		ap.current = nil
+	}
+
+	failed := make(map[*PublishFuture]bool)
+	for _, future := range cc.pending {
+		failed[future] = true
+	}
+
+	cc.pending = nil
+	ap.lock.Unlock()
+
+	for future := range failed {
+		pub.completeFuture(future, models.FailurePublish, errors.New("channel closed before the letter was confirmed"))
+		reporter.push(future)
+	}
+
+	pub.ChannelPool.FlagChannel(cc.chanHost.ChannelID)
+	pub.ChannelPool.ReturnChannel(cc.chanHost, false)
+}
+
+// FutureReporter reports completed futures, in the order they completed, on its own goroutine.
+type futureReporter struct {
+	queue  []*PublishFuture
+	closed bool
+	ready  chan struct{}
+	lock   *sync.Mutex
+}
+
+func newFutureReporter() *futureReporter {
+	return &futureReporter{
+		ready: make(chan struct{}, 1),
+		lock:  &sync.Mutex{},
+	}
+}
+
+// Push queues the future to be reported, never blocks.
+func (reporter *futureReporter) push(future *PublishFuture) {
+	reporter.lock.Lock()
+	reporter.queue = append(reporter.queue, future)
+	reporter.lock.Unlock()
+
+	reporter.signal()
+}
+
+// Close lets run return once the futures already queued are reported.
+func (reporter *futureReporter) close() {
+	reporter.lock.Lock()
+	reporter.closed = true
+	reporter.lock.Unlock()
+
+	reporter.signal()
+}
+
+func (reporter *futureReporter) signal() {
+	select {
+	case reporter.ready <- struct{}{}:
+	default: // already signaled
+	}
+}
+
+// Run reports the queued futures until the reporter is closed.
+func (reporter *futureReporter) run(pub *Publisher) {
+	for {
+		reporter.lock.Lock()
+		queue, closed := reporter.queue, reporter.closed
+		reporter.queue = nil
+		reporter.lock.Unlock()
+
+		for _, future := range queue {
+			pub.reportFuture(future)
+		}
+
+		if closed && len(queue) == 0 {
+			return
+		}
+
+		if len(queue) == 0 {
+			<-reporter.ready
+		}
+	}
+}
+
+// CollectReturns moves the ReturnMessages received so far into the returned letters of the confirm channel.
+// The server sends a basic.return before the confirm of the same message so a returned letter is always seen first.
+func (ap *asyncPublisher) collectReturns(cc *confirmChannel) {
+	for {
+		select {
+		case ret := <-cc.chanHost.Returns():
+			cc.returned[ret.MessageID] = ret
+		default:
+			return
+		}
+	}
+}
+
+// Close closes the confirm channel, letters still waiting on a confirm fail.
+func (ap *asyncPublisher) close() {
+	ap.lock.Lock()
+	cc := ap.current
+	ap.current = nil
+	ap.lock.Unlock()
+
+	if cc != nil {
+		_ = cc.chanHost.Channel.Close()
+	}
+}
@@ -0,0 +1,39 @@
+package publisher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+	"github.com/prom3t3us/turbocookedrabbit/utils"
+)
+
+func TestFutureReporterDoesntHoldUpFutures(t *testing.T) {
+
+	notifications := make(chan *models.Notification) // nobody reads them yet
+	pub := &Publisher{
+		notifications:        notifications,
+		notificationDispatch: utils.NewNotificationDispatcher(notifications, models.OverflowBlock),
+		middleware:           newMiddleware(),
+		stats:                newPublisherStats(),
+	}
+
+	reporter := newFutureReporter()
+	go reporter.run(pub)
+
+	futures := make([]*PublishFuture, 3)
+	for i := range futures {
+		futures[i] = newPublishFuture(&models.Letter{LetterID: uint64(i)})
+		pub.completeFuture(futures[i], models.FailurePublish, nil)
+		reporter.push(futures[i])
+
+		<-futures[i].Done() // done before its Notification could be sent
+		assert.True(t, futures[i].Notification().Success)
+	}
+	reporter.close()
+
+	for i := range futures {
+		assert.Equal(t, uint64(i), (<-notifications).LetterID)
+	}
+}
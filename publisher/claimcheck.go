@@ -0,0 +1,73 @@
+package publisher
+
+import (
+	"fmt"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+	"github.com/prom3t3us/turbocookedrabbit/utils"
+)
+
+// SetBlobStore sets where Bodies larger than the ClaimCheckThreshold are kept, only a reference (a key unique to
+// each stored Body) is published in the ClaimCheckHeader. Nil publishes every Body as is.
+func (pub *Publisher) SetBlobStore(store utils.BlobStore) {
+	pub.blobStoreLock.Lock()
+	defer pub.blobStoreLock.Unlock()
+
+	pub.blobStore = store
+}
+
+func (pub *Publisher) getBlobStore() utils.BlobStore {
+	pub.blobStoreLock.RLock()
+	defer pub.blobStoreLock.RUnlock()
+
+	return pub.blobStore
+}
+
+// ClaimCheck moves the letter's Body to the BlobStore when it's larger than the ClaimCheckThreshold and returns a copy
+// of the letter carrying the reference instead. Returns the letter itself when there's nothing to do.
+func (pub *Publisher) claimCheck(letter *models.Letter) (*models.Letter, error) {
+
+	if pub.claimCheckThreshold == 0 || uint64(len(letter.Body)) <= pub.claimCheckThreshold {
+		return letter, nil
+	}
+
+	store := pub.getBlobStore()
+	if store == nil {
+		return letter, nil
+	}
+
+	key := utils.NewUUID() // MessageIDs can repeat, a republished letter must not share or delete another's Body
+	if err := store.Put(key, letter.Body); err != nil {
+		return nil, fmt.Errorf("can't store the letter's body for claim-check: %s", err)
+	}
+
+	claimed := *letter
+	envelope := *letter.Envelope
+	claimed.Envelope = &envelope
+	claimed.Body = nil
+
+	envelope.Headers = make(map[string]interface{}, len(letter.Envelope.Headers)+1)
+	for key, value := range letter.Envelope.Headers {
+		envelope.Headers[key] = value
+	}
+
+	envelope.Headers[models.ClaimCheckHeader] = key
+
+	return &claimed, nil
+}
+
+// ReleaseClaimCheck deletes the stored Body of a claim-checked letter that was never published.
+func (pub *Publisher) releaseClaimCheck(letter *models.Letter, outgoing *models.Letter) {
+	if outgoing == letter {
+		return
+	}
+
+	key, ok := outgoing.Envelope.Headers[models.ClaimCheckHeader].(string)
+	if !ok {
+		return
+	}
+
+	if store := pub.getBlobStore(); store != nil {
+		_ = store.Delete(key)
+	}
+}
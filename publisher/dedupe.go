@@ -0,0 +1,64 @@
+package publisher
+
+import (
+	"sync"
+	"time"
+)
+
+// DedupeWindow remembers claimed MessageIDs for a period of time so the same letter isn't published twice.
+type dedupeWindow struct {
+	window    time.Duration
+	claimed   map[string]time.Time
+	lastSweep time.Time
+	lock      *sync.Mutex
+}
+
+// NewDedupeWindow builds a dedupeWindow, returns nil when the window is zero (disabled).
+func newDedupeWindow(window time.Duration) *dedupeWindow {
+	if window <= 0 {
+		return nil
+	}
+
+	return &dedupeWindow{
+		window:    window,
+		claimed:   make(map[string]time.Time),
+		lastSweep: time.Now(),
+		lock:      &sync.Mutex{},
+	}
+}
+
+// Claim records the MessageID, returns false when it has already been claimed within the window.
+func (dw *dedupeWindow) claim(messageID string, now time.Time) bool {
+	dw.lock.Lock()
+	defer dw.lock.Unlock()
+
+	if now.Sub(dw.lastSweep) >= dw.window {
+		dw.sweep(now)
+	}
+
+	if claimedAt, ok := dw.claimed[messageID]; ok && now.Sub(claimedAt) < dw.window {
+		return false
+	}
+
+	dw.claimed[messageID] = now
+	return true
+}
+
+// Release forgets the MessageID so the letter can be submitted again (used when publishing failed).
+func (dw *dedupeWindow) release(messageID string) {
+	dw.lock.Lock()
+	defer dw.lock.Unlock()
+
+	delete(dw.claimed, messageID)
+}
+
+// Sweep removes claims that have fallen out of the window.
+func (dw *dedupeWindow) sweep(now time.Time) {
+	for messageID, claimedAt := range dw.claimed {
+		if now.Sub(claimedAt) >= dw.window {
+			delete(dw.claimed, messageID)
+		}
+	}
+
+	dw.lastSweep = now
+}
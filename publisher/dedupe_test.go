@@ -0,0 +1,60 @@
+package publisher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupeWindowDisabled(t *testing.T) {
+	assert.Nil(t, newDedupeWindow(0))
+	assert.Nil(t, newDedupeWindow(-time.Second))
+}
+
+func TestDedupeWindowClaim(t *testing.T) {
+
+	start := time.Now()
+	window := newDedupeWindow(time.Second)
+	window.lastSweep = start
+
+	tests := []struct {
+		messageID string
+		at        time.Duration
+		claimed   bool
+	}{
+		{messageID: "a", at: 0, claimed: true},
+		{messageID: "a", at: 500 * time.Millisecond, claimed: false}, // duplicate within the window
+		{messageID: "b", at: 500 * time.Millisecond, claimed: true},
+		{messageID: "a", at: time.Second, claimed: true},              // fell out of the window
+		{messageID: "b", at: 1400 * time.Millisecond, claimed: false}, // still within its window
+		{messageID: "b", at: 1500 * time.Millisecond, claimed: true},
+	}
+
+	for i, test := range tests {
+		assert.Equal(t, test.claimed, window.claim(test.messageID, start.Add(test.at)), "claim %d", i)
+	}
+}
+
+func TestDedupeWindowReleaseAndSweep(t *testing.T) {
+
+	start := time.Now()
+	window := newDedupeWindow(time.Second)
+	window.lastSweep = start
+
+	assert.True(t, window.claim("a", start))
+	window.release("a") // publishing failed, it can be submitted again
+	assert.True(t, window.claim("a", start))
+
+	for _, messageID := range []string{"b", "c", "d"} {
+		assert.True(t, window.claim(messageID, start.Add(200*time.Millisecond)))
+	}
+
+	assert.Equal(t, 4, len(window.claimed))
+
+	// Claims are swept once a window went by.
+	assert.True(t, window.claim("e", start.Add(1100*time.Millisecond)))
+	assert.Equal(t, 4, len(window.claimed))
+	assert.True(t, window.claim("f", start.Add(2200*time.Millisecond)))
+	assert.Equal(t, 1, len(window.claimed))
+}
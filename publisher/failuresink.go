@@ -0,0 +1,160 @@
+package publisher
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+)
+
+// Headers added to letters sent to the failure sink.
+const (
+	FailureReasonHeader      = "x-failure-reason"
+	FailureAttemptsHeader    = "x-failure-attempts"
+	FailureHistoryHeader     = "x-failure-history"
+	OriginalExchangeHeader   = "x-original-exchange"
+	OriginalRoutingKeyHeader = "x-original-routing-key"
+)
+
+const (
+	failureSinkFilePermission = 0644
+	defaultFailureSinkTimeout = 5 * time.Second
+)
+
+// AttemptFailure is why a single publish attempt failed.
+type attemptFailure struct {
+	at  time.Time
+	err error
+}
+
+// FailureSink keeps letters that ran out of publish attempts.
+type failureSink struct {
+	config   *models.FailureSinkConfig
+	timeout  time.Duration
+	fileLock *sync.Mutex
+}
+
+// NewFailureSink builds a failureSink, returns nil when there's nowhere to keep failed letters.
+func newFailureSink(config *models.FailureSinkConfig) *failureSink {
+	if config == nil || (config.Exchange == "" && config.FilePath == "") {
+		return nil
+	}
+
+	sink := &failureSink{
+		config:   config,
+		timeout:  time.Duration(config.Timeout) * time.Millisecond,
+		fileLock: &sync.Mutex{},
+	}
+
+	if sink.timeout <= 0 {
+		sink.timeout = defaultFailureSinkTimeout
+	}
+
+	return sink
+}
+
+// SinkFailedLetter republishes the letter, with its failure headers, to the failed letters exchange
+// and falls back to the file when that isn't possible. Errors from the sink itself are sent to Notifications.
+func (pub *Publisher) sinkFailedLetter(letter *models.Letter, reason models.FailureReason, history []attemptFailure) {
+
+	failedLetter := failedLetterFor(letter, reason, history)
+
+	var err error
+	if pub.failureSink.config.Exchange != "" {
+		failedLetter.Envelope.Exchange = pub.failureSink.config.Exchange
+		if pub.failureSink.config.RoutingKey != "" {
+			failedLetter.Envelope.RoutingKey = pub.failureSink.config.RoutingKey
+		}
+
+		if err = pub.republishFailedLetter(failedLetter); err == nil {
+			return
+		}
+	}
+
+	if pub.failureSink.config.FilePath != "" {
+		err = pub.failureSink.writeToFile(failedLetter)
+	}
+
+	if err != nil {
+		pub.sendFailureToNotifications(letter, reason, fmt.Errorf("failure sink couldn't keep the letter: %w", err))
+	}
+}
+
+// FailedLetterFor copies the letter with the failure reason and attempt history added to its headers.
+func failedLetterFor(letter *models.Letter, reason models.FailureReason, history []attemptFailure) *models.Letter {
+
+	failedLetter := *letter
+	envelope := *letter.Envelope
+	failedLetter.Envelope = &envelope
+	failedLetter.Deadline = time.Time{}
+
+	attempts := make([]interface{}, len(history))
+	for i, attempt := range history {
+		attempts[i] = fmt.Sprintf("%s %s", attempt.at.Format(time.RFC3339Nano), attempt.err)
+	}
+
+	envelope.Headers = make(map[string]interface{}, len(letter.Envelope.Headers)+5)
+	for key, value := range letter.Envelope.Headers {
+		envelope.Headers[key] = value
+	}
+
+	envelope.Headers[FailureReasonHeader] = reason.String()
+	envelope.Headers[FailureAttemptsHeader] = int32(len(history))
+	envelope.Headers[FailureHistoryHeader] = attempts
+	envelope.Headers[OriginalExchangeHeader] = letter.Envelope.Exchange
+	envelope.Headers[OriginalRoutingKeyHeader] = letter.Envelope.RoutingKey
+
+	return &failedLetter
+}
+
+// RepublishFailedLetter makes a single attempt to publish the failed letter (no hooks, no notifications).
+// Letters usually run out of attempts during an outage, when GetChannel would keep retrying, so this only takes a
+// healthy channel that frees up within the sink's Timeout and otherwise leaves the letter for the file.
+func (pub *Publisher) republishFailedLetter(failedLetter *models.Letter) error {
+
+	chanHost, err := pub.ChannelPool.TryGetChannel(pub.failureSink.timeout)
+	if err != nil {
+		return fmt.Errorf("couldn't get a channel within %s: %w", pub.failureSink.timeout, err)
+	}
+
+	err = pub.publishLetter(chanHost.Channel, failedLetter)
+	pub.ChannelPool.ReturnChannel(chanHost, err != nil)
+	return err
+}
+
+// WriteToFile appends the failed letter to the sink file as a line of JSON.
+func (sink *failureSink) writeToFile(failedLetter *models.Letter) error {
+
+	record := &models.FailedLetterRecord{
+		LetterID:   failedLetter.LetterID,
+		MessageID:  failedLetter.MessageID,
+		Exchange:   failedLetter.Envelope.Headers[OriginalExchangeHeader].(string),
+		RoutingKey: failedLetter.Envelope.Headers[OriginalRoutingKeyHeader].(string),
+		Headers:    failedLetter.Envelope.Headers,
+		Body:       failedLetter.Body,
+		FailedAt:   time.Now().UTC(),
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	sink.fileLock.Lock()
+	defer sink.fileLock.Unlock()
+
+	file, err := os.OpenFile(sink.config.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, failureSinkFilePermission)
+	if err != nil {
+		return err
+	}
+
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,94 @@
+package publisher
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// LetterLane queues the letters of an ordered AutoPublish worker. Queuing never blocks, the letters waiting on every
+// lane are bounded by the LetterBuffer (plus MaxOverBuffer), so a busy ordering key doesn't hold up the other lanes.
+type letterLane struct {
+	queue  []*queuedLetter
+	closed bool
+	ready  chan struct{} // signaled when a letter is queued or the lane is closed
+	lock   *sync.Mutex
+}
+
+func newLetterLane() *letterLane {
+	return &letterLane{
+		ready: make(chan struct{}, 1),
+		lock:  &sync.Mutex{},
+	}
+}
+
+func (lane *letterLane) push(queued *queuedLetter) {
+	lane.lock.Lock()
+	lane.queue = append(lane.queue, queued)
+	lane.lock.Unlock()
+
+	lane.signal()
+}
+
+// Close lets the lane's worker finish the letters already queued then stop.
+func (lane *letterLane) close() {
+	lane.lock.Lock()
+	lane.closed = true
+	lane.lock.Unlock()
+
+	lane.signal()
+}
+
+func (lane *letterLane) signal() {
+	select {
+	case lane.ready <- struct{}{}:
+	default: // already signaled
+	}
+}
+
+// Feed hands the queued letters, in order, to the lane's worker and closes letters once the lane is closed and empty.
+func (lane *letterLane) feed(letters chan<- *queuedLetter) {
+	defer close(letters)
+
+	for {
+		lane.lock.Lock()
+		if len(lane.queue) == 0 {
+			closed := lane.closed
+			lane.lock.Unlock()
+
+			if closed {
+				return
+			}
+
+			<-lane.ready
+			continue
+		}
+
+		queued := lane.queue[0]
+		lane.queue[0] = nil
+		lane.queue = lane.queue[1:]
+		lane.lock.Unlock()
+
+		letters <- queued
+	}
+}
+
+// DispatchToLanes hands each queued letter to the lane (worker) owning its ordering key until stopped,
+// then closes the lanes so the workers finish what they have been given.
+func (pub *Publisher) dispatchToLanes(lanes []*letterLane, stop <-chan struct{}) {
+	defer pub.autoPublishGroup.Done()
+
+	for {
+		select {
+		case <-stop:
+			for _, lane := range lanes {
+				lane.close()
+			}
+			return
+		case queued := <-pub.letters:
+			hash := fnv.New32a()
+			_, _ = hash.Write([]byte(queued.letter.GetOrderingKey()))
+
+			lanes[hash.Sum32()%uint32(len(lanes))].push(queued)
+		}
+	}
+}
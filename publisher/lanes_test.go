@@ -0,0 +1,51 @@
+package publisher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+)
+
+func TestLetterLaneFeed(t *testing.T) {
+
+	lane := newLetterLane()
+
+	// Queuing doesn't wait on the worker.
+	for i := uint64(1); i <= 3; i++ {
+		lane.push(&queuedLetter{letter: &models.Letter{LetterID: i}})
+	}
+	lane.close()
+
+	letters := make(chan *queuedLetter)
+	go lane.feed(letters)
+
+	received := make([]uint64, 0)
+	for queued := range letters {
+		received = append(received, queued.letter.LetterID)
+	}
+
+	assert.Equal(t, []uint64{1, 2, 3}, received)
+}
+
+func TestLetterLaneFeedWaits(t *testing.T) {
+
+	lane := newLetterLane()
+	letters := make(chan *queuedLetter)
+	go lane.feed(letters)
+
+	select {
+	case <-letters:
+		t.Fatal("an empty lane shouldn't feed its worker")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	lane.push(&queuedLetter{letter: &models.Letter{LetterID: 1}})
+	assert.Equal(t, uint64(1), (<-letters).letter.LetterID)
+
+	lane.close()
+	_, ok := <-letters
+	assert.False(t, ok)
+}
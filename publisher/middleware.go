@@ -0,0 +1,68 @@
+package publisher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+)
+
+// BeforePublish runs before a letter is published and can modify it (headers, body, etc.).
+// Returning an error rejects the letter, it isn't published and is reported with the FailureRejected reason.
+type BeforePublish func(letter *models.Letter) error
+
+// AfterPublish runs once a letter is done, err is nil when it was published. Duration covers every attempt.
+type AfterPublish func(letter *models.Letter, err error, duration time.Duration)
+
+// Middleware holds the publish hooks, called in the order they were added.
+type middleware struct {
+	before []BeforePublish
+	after  []AfterPublish
+	lock   *sync.RWMutex
+}
+
+func newMiddleware() *middleware {
+	return &middleware{
+		lock: &sync.RWMutex{},
+	}
+}
+
+func (mw *middleware) addBefore(hooks ...BeforePublish) {
+	mw.lock.Lock()
+	defer mw.lock.Unlock()
+
+	mw.before = append(mw.before, hooks...)
+}
+
+func (mw *middleware) addAfter(hooks ...AfterPublish) {
+	mw.lock.Lock()
+	defer mw.lock.Unlock()
+
+	mw.after = append(mw.after, hooks...)
+}
+
+// RunBefore calls the before hooks, stopping at the first one to reject the letter.
+func (mw *middleware) runBefore(letter *models.Letter) error {
+	mw.lock.RLock()
+	hooks := mw.before
+	mw.lock.RUnlock()
+
+	for _, hook := range hooks {
+		if err := hook(letter); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RunAfter calls every after hook.
+func (mw *middleware) runAfter(letter *models.Letter, err error, duration time.Duration) {
+	mw.lock.RLock()
+	hooks := mw.after
+	mw.lock.RUnlock()
+
+	for _, hook := range hooks {
+		hook(letter, err, duration)
+	}
+}
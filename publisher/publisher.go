@@ -1,33 +1,66 @@
 package publisher
 
 import (
+	"fmt"
+	"runtime"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/prom3t3us/turbocookedrabbit/models"
 	"github.com/prom3t3us/turbocookedrabbit/pools"
+	"github.com/prom3t3us/turbocookedrabbit/topology"
+	"github.com/prom3t3us/turbocookedrabbit/utils"
 
 	"github.com/streadway/amqp"
 )
 
 // Publisher contains everything you need to publish a message.
 type Publisher struct {
-	Config                   *models.RabbitSeasoning
-	ChannelPool              *pools.ChannelPool
-	letters                  chan *models.Letter
-	letterCount              uint64
-	letterBuffer             uint64
-	maxOverBuffer            uint64
-	autoStop                 chan bool
-	notifications            chan *models.Notification
-	autoStarted              bool
-	autoPublishGroup         *sync.WaitGroup
-	sleepOnIdleInterval      time.Duration
-	sleepOnQueueFullInterval time.Duration
-	sleepOnErrorInterval     time.Duration
-	pubLock                  *sync.Mutex
-	pubRWLock                *sync.RWMutex
+	Config               *models.RabbitSeasoning
+	ChannelPool          *pools.ChannelPool
+	letters              chan *queuedLetter
+	letterCount          uint64
+	letterBuffer         uint64
+	maxOverBuffer        uint64
+	autoStop             chan bool
+	notifications        chan *models.Notification
+	notificationDispatch *utils.Dispatcher
+	autoStarted          bool
+	autoPublishGroup     *sync.WaitGroup
+	queueRoom            *sync.Cond
+	sleepOnErrorInterval time.Duration
+	pubLock              *sync.Mutex
+	pubRWLock            *sync.RWMutex
+	rateLimiter          *rateLimiter
+	orderedAutoPublish   bool
+	workerCount          uint32
+	busyWorkers          int32
+	saturatedQueue       uint64
+	messageIDGenerator   *atomic.Value
+	dedupe               *dedupeWindow
+	middleware           *middleware
+	delayConfig          *models.DelayConfig
+	topologer            *topology.Topologer
+	waitQueues           map[string]time.Time // when each wait queue was last declared
+	scheduledLetters     map[*time.Timer]*models.Letter
+	scheduleLock         *sync.Mutex
+	failureSink          *failureSink
+	chunkSize            uint64
+	claimCheckThreshold  uint64
+	blobStore            utils.BlobStore
+	blobStoreLock        *sync.RWMutex
+	stats                *publisherStats
+	async                *asyncPublisher
+	txChannel            *pools.ChannelHost // kept in transaction mode for the next Transaction
+	txLock               *sync.Mutex
+}
+
+// QueuedLetter is a letter waiting on AutoPublish and when it was queued.
+type queuedLetter struct {
+	letter   *models.Letter
+	queuedAt time.Time
 }
 
 // NewPublisher creates and configures a new Publisher.
@@ -45,39 +78,91 @@ func NewPublisher(
 		}
 	}
 
-	return &Publisher{
-		Config:                   config,
-		ChannelPool:              chanPool,
-		letters:                  make(chan *models.Letter, config.PublisherConfig.LetterBuffer),
-		letterBuffer:             config.PublisherConfig.LetterBuffer,
-		maxOverBuffer:            config.PublisherConfig.MaxOverBuffer,
-		autoStop:                 make(chan bool, 1),
-		autoPublishGroup:         &sync.WaitGroup{},
-		notifications:            make(chan *models.Notification, config.PublisherConfig.NotificationBuffer),
-		sleepOnIdleInterval:      time.Duration(config.PublisherConfig.SleepOnIdleInterval) * time.Millisecond,
-		sleepOnQueueFullInterval: time.Duration(config.PublisherConfig.SleepOnQueueFullInterval) * time.Millisecond,
-		sleepOnErrorInterval:     time.Duration(config.PublisherConfig.SleepOnErrorInterval) * time.Millisecond,
-		pubLock:                  &sync.Mutex{},
-		pubRWLock:                &sync.RWMutex{},
-		autoStarted:              false,
-	}, nil
+	pub := &Publisher{
+		Config:               config,
+		ChannelPool:          chanPool,
+		letters:              make(chan *queuedLetter, config.PublisherConfig.LetterBuffer),
+		letterBuffer:         config.PublisherConfig.LetterBuffer,
+		maxOverBuffer:        config.PublisherConfig.MaxOverBuffer,
+		autoStop:             make(chan bool, 1),
+		autoPublishGroup:     &sync.WaitGroup{},
+		notifications:        make(chan *models.Notification, config.PublisherConfig.NotificationBuffer),
+		sleepOnErrorInterval: time.Duration(config.PublisherConfig.SleepOnErrorInterval) * time.Millisecond,
+		pubLock:              &sync.Mutex{},
+		pubRWLock:            &sync.RWMutex{},
+		rateLimiter:          newRateLimiter(config.PublisherConfig.RateLimitConfig),
+		orderedAutoPublish:   config.PublisherConfig.OrderedAutoPublish,
+		workerCount:          autoPublishWorkerCount(config.PublisherConfig.PublishWorkerCount, chanPool),
+		messageIDGenerator:   &atomic.Value{},
+		middleware:           newMiddleware(),
+		delayConfig:          config.PublisherConfig.DelayConfig,
+		waitQueues:           make(map[string]time.Time),
+		scheduledLetters:     make(map[*time.Timer]*models.Letter),
+		scheduleLock:         &sync.Mutex{},
+		failureSink:          newFailureSink(config.PublisherConfig.FailureSinkConfig),
+		chunkSize:            config.PublisherConfig.ChunkSize,
+		claimCheckThreshold:  config.PublisherConfig.ClaimCheckThreshold,
+		blobStoreLock:        &sync.RWMutex{},
+		stats:                newPublisherStats(),
+		async:                newAsyncPublisher(),
+		txLock:               &sync.Mutex{},
+		dedupe:               newDedupeWindow(time.Duration(config.PublisherConfig.DedupeWindow) * time.Millisecond),
+		autoStarted:          false,
+	}
+
+	pub.queueRoom = sync.NewCond(pub.pubRWLock)
+
+	switch config.PublisherConfig.MessageIDFormat {
+	case models.MessageIDULID:
+		pub.messageIDGenerator.Store(utils.NewULID)
+	default:
+		pub.messageIDGenerator.Store(utils.NewUUID)
+	}
+
+	pub.notificationDispatch = utils.NewNotificationDispatcher(
+		pub.notifications,
+		config.PublisherConfig.NotificationOverflowPolicy)
+
+	return pub, nil
 }
 
 // Publish sends a single message to the address on the letter.
+// Blocks while the letter's rate limit has been reached.
 // Subscribe to Notifications to see success and errors.
 func (pub *Publisher) Publish(letter *models.Letter) {
 
-	chanHost, err := pub.ChannelPool.GetChannel()
-	if err != nil {
-		pub.sendToNotifications(letter, err)
-		return // exit out if you can't get a channel
+	if !pub.acceptLetter(letter) {
+		return
 	}
 
-	err = pub.simplePublish(chanHost.Channel, letter)
-	if err != nil {
-		pub.handleErrorAndChannel(err, letter, chanHost)
-	} else {
-		pub.sendToNotifications(letter, err)
+	if pub.rateLimiter != nil {
+		pub.rateLimiter.wait(letter)
+	}
+
+	pub.publish(letter)
+}
+
+// TryPublish sends a single message to the address on the letter without waiting on the rate limit.
+// Returns false, without publishing, when the letter's rate limit has been reached or the letter is a duplicate.
+// Subscribe to Notifications to see success and errors.
+func (pub *Publisher) TryPublish(letter *models.Letter) bool {
+
+	if !pub.acceptLetter(letter) {
+		return false
+	}
+
+	if pub.rateLimiter != nil && !pub.rateLimiter.allow(letter) {
+		pub.releaseLetter(letter)
+		return false
+	}
+
+	pub.publish(letter)
+	return true
+}
+
+func (pub *Publisher) publish(letter *models.Letter) {
+
+	if chanHost := pub.publishAttempts(nil, letter, 1); chanHost != nil {
 		pub.ChannelPool.ReturnChannel(chanHost, false)
 	}
 }
@@ -85,78 +170,220 @@ func (pub *Publisher) Publish(letter *models.Letter) {
 // PublishWithRetry sends a single message to the address on the letter with retry capabilities.
 // Subscribe to Notifications to see success and errors.
 // RetryCount is based on the letter property. Zero means it will try once.
+// Every attempt waits on the letter's rate limit and the letter is dropped once its Deadline passes.
+// Every attempt uses the same MessageID so consumers can spot a retried duplicate.
 func (pub *Publisher) PublishWithRetry(letter *models.Letter) {
 
-	for i := letter.RetryCount + 1; i > 0; i-- {
-		chanHost, err := pub.ChannelPool.GetChannel()
-		if err != nil {
-			time.Sleep(pub.sleepOnErrorInterval * time.Millisecond)
-			continue // can't get a channel
+	if !pub.acceptLetter(letter) {
+		return
+	}
+
+	pub.publishWithRetry(letter)
+}
+
+func (pub *Publisher) publishWithRetry(letter *models.Letter) {
+
+	if pub.rateLimiter != nil {
+		pub.rateLimiter.wait(letter)
+	}
+
+	if chanHost := pub.publishAttempts(nil, letter, letter.RetryCount+1); chanHost != nil {
+		pub.ChannelPool.ReturnChannel(chanHost, false)
+	}
+}
+
+// PublishAttempts tries to publish the letter up to attempts times on the ChannelHost provided, getting a new
+// ChannelHost from the ChannelPool whenever it doesn't have a healthy one. Returns the healthy ChannelHost it finished
+// with (nil if it has none) so the caller can return it or hold on to it. The caller waits on the rate limit for the
+// first attempt, retries wait here.
+// The BeforePublish hooks run once before the first attempt and the AfterPublish hooks once with the final result.
+// A letter that runs out of attempts is sent to the failure sink, when there is one.
+// A Body over the ClaimCheckThreshold is stored once, before the first attempt, and deleted if every attempt fails.
+func (pub *Publisher) publishAttempts(chanHost *pools.ChannelHost, letter *models.Letter, attempts uint32) *pools.ChannelHost {
+
+	start := time.Now()
+
+	err := pub.middleware.runBefore(letter)
+	if err != nil {
+		atomic.AddUint64(&pub.stats.failed, 1)
+		pub.releaseLetter(letter)
+		pub.sendFailureToNotifications(letter, models.FailureRejected, err)
+		pub.middleware.runAfter(letter, err, time.Since(start))
+		return chanHost
+	}
+
+	outgoing, err := pub.claimCheck(letter)
+	if err != nil {
+		atomic.AddUint64(&pub.stats.failed, 1)
+		pub.releaseLetter(letter)
+		pub.sendToNotifications(letter, err)
+		pub.middleware.runAfter(letter, err, time.Since(start))
+		return chanHost
+	}
+
+	var history []attemptFailure
+	for i := uint32(0); i < attempts; i++ {
+		if i > 0 {
+			atomic.AddUint64(&pub.stats.retried, 1)
+			if pub.rateLimiter != nil {
+				pub.rateLimiter.wait(letter)
+			}
 		}
 
-		err = pub.simplePublish(chanHost.Channel, letter)
+		if letter.Expired() {
+			pub.releaseClaimCheck(letter, outgoing)
+			err = pub.dropExpiredLetter(letter)
+			pub.middleware.runAfter(letter, err, time.Since(start))
+			return chanHost // no point in retrying
+		}
+
+		if chanHost == nil {
+			checkoutStart := time.Now()
+			chanHost, err = pub.ChannelPool.GetChannel()
+			pub.stats.channelCheckout.observe(time.Since(checkoutStart))
+			if err != nil {
+				chanHost = nil
+				history = append(history, attemptFailure{at: time.Now(), err: err})
+				if i == attempts-1 {
+					pub.sendToNotifications(letter, err)
+				} else {
+					time.Sleep(pub.sleepOnErrorInterval)
+				}
+				continue // can't get a channel
+			}
+		}
+
+		publishStart := time.Now()
+		err = pub.publishLetter(chanHost.Channel, outgoing)
+		pub.stats.publish.observe(time.Since(publishStart))
 		if err != nil {
+			history = append(history, attemptFailure{at: time.Now(), err: err})
 			pub.handleErrorAndChannel(err, letter, chanHost)
+			chanHost = nil
 			continue // flag channel and try again
 		}
 
-		pub.sendToNotifications(letter, err)
-		pub.ChannelPool.ReturnChannel(chanHost, false)
-		break // finished
+		atomic.AddUint64(&pub.stats.published, 1)
+		pub.sendToNotifications(letter, nil)
+		pub.middleware.runAfter(letter, nil, time.Since(start))
+		return chanHost // finished
+	}
+
+	atomic.AddUint64(&pub.stats.failed, 1)
+	pub.releaseLetter(letter)
+	pub.releaseClaimCheck(letter, outgoing)
+	if pub.failureSink != nil {
+		pub.sinkFailedLetter(letter, models.FailurePublish, history)
 	}
+
+	pub.middleware.runAfter(letter, err, time.Since(start))
+	return chanHost
+}
+
+// UseBeforePublish adds hooks that run before every letter is published (Publish, PublishWithRetry and AutoPublish).
+// Hooks can modify the letter or reject it by returning an error.
+func (pub *Publisher) UseBeforePublish(hooks ...BeforePublish) {
+	pub.middleware.addBefore(hooks...)
+}
+
+// UseAfterPublish adds hooks that see the result, and how long it took, of every letter published
+// (Publish, PublishWithRetry and AutoPublish), including rejected and expired letters.
+func (pub *Publisher) UseAfterPublish(hooks ...AfterPublish) {
+	pub.middleware.addAfter(hooks...)
 }
 
 func (pub *Publisher) handleErrorAndChannel(err error, letter *models.Letter, chanHost *pools.ChannelHost) {
 	pub.ChannelPool.ReturnChannel(chanHost, true)
 	pub.sendToNotifications(letter, err)
-	time.Sleep(pub.sleepOnErrorInterval * time.Millisecond)
+	time.Sleep(pub.sleepOnErrorInterval)
 }
 
 // Notifications yields all the success and failures during all publish events. Highly recommend susbscribing to this.
-// Buffer will block if not consumed and leave goroutines stuck.
+// When the buffer is full the NotificationOverflowPolicy decides what happens, by default the oldest is dropped.
 func (pub *Publisher) Notifications() <-chan *models.Notification {
 	return pub.notifications
 }
 
+// SetNotificationHandler calls handler with every notification, synchronously, instead of using the Notifications
+// channel. The handler runs on the publishing goroutine so keep it quick. Nil restores the channel.
+func (pub *Publisher) SetNotificationHandler(handler func(*models.Notification)) {
+	pub.notificationDispatch.SetNotificationHandler(handler)
+}
+
+// SetNotificationOverflowHandler calls handler with notifications that don't fit in the Notifications buffer.
+// Only used with the Callback NotificationOverflowPolicy.
+func (pub *Publisher) SetNotificationOverflowHandler(handler func(*models.Notification)) {
+	pub.notificationDispatch.SetNotificationOverflowHandler(handler)
+}
+
+// DroppedNotifications lets you know how many notifications were dropped because the buffer was full.
+func (pub *Publisher) DroppedNotifications() uint64 {
+	return pub.notificationDispatch.Dropped()
+}
+
+// AutoPublishWorkerCount gets how many workers AutoPublish runs. Every worker holds a channel while AutoPublish is
+// running and GetChannel blocks on an empty pool, so workers holding every channel would hang everything else sharing
+// the ChannelPool: they are kept under the MaxChannelCount, by default one per CPU up to half of the channels.
+// There's always at least one worker, even when the pool is too small to leave it a channel.
+func autoPublishWorkerCount(configured uint32, chanPool *pools.ChannelPool) uint32 {
+
+	maxWorkers := uint64(1)
+	if chanPool.Config.ChannelPoolConfig != nil && chanPool.Config.ChannelPoolConfig.MaxChannelCount > 1 {
+		maxWorkers = chanPool.Config.ChannelPoolConfig.MaxChannelCount - 1
+	}
+
+	workerCount := uint64(configured)
+	if workerCount == 0 {
+		workerCount = uint64(runtime.NumCPU())
+		if half := (maxWorkers + 1) / 2; workerCount > half {
+			workerCount = half
+		}
+	}
+
+	if workerCount > maxWorkers {
+		workerCount = maxWorkers
+	}
+
+	return uint32(workerCount)
+}
+
 // StartAutoPublish starts auto-publishing letters queued up - is locking.
+// A fixed number of workers (PublishWorkerCount) publish the queued letters, each one holding a channel from the
+// ChannelPool while AutoPublish is running. Queued letters still wait on their rate limits before being published.
+// With OrderedAutoPublish, letters sharing an ordering key are always published by the same worker, one at a time
+// and in order, while different keys are published in parallel. A busy key only holds up the keys sharing its worker.
 func (pub *Publisher) StartAutoPublish(allowRetry bool) {
 	pub.FlushStops()
 
-	go func() {
-	PublishLoop:
-		for {
-			select {
-			case stop := <-pub.autoStop:
-				if stop {
-					break PublishLoop
-				}
-			default:
-				break
-			}
+	stop := make(chan struct{})
+	if pub.orderedAutoPublish {
+		lanes := make([]*letterLane, pub.workerCount)
+		for i := range lanes {
+			lanes[i] = newLetterLane()
+			letters := make(chan *queuedLetter)
+			go lanes[i].feed(letters)
 
-			select {
-			case letter := <-pub.letters:
-				pub.autoPublishGroup.Add(1)
-
-				go func() {
-					defer pub.autoPublishGroup.Done()
-					if allowRetry {
-						pub.PublishWithRetry(letter)
-					} else {
-						pub.Publish(letter)
-					}
+			pub.autoPublishGroup.Add(1)
+			go pub.publishWorker(letters, nil, allowRetry)
+		}
 
-					pub.reduceLetterCount()
-				}()
+		pub.autoPublishGroup.Add(1)
+		go pub.dispatchToLanes(lanes, stop)
+	} else {
+		for i := uint32(0); i < pub.workerCount; i++ {
+			pub.autoPublishGroup.Add(1)
+			go pub.publishWorker(pub.letters, stop, allowRetry)
+		}
+	}
 
-			default:
-				if pub.sleepOnIdleInterval > 0 {
-					time.Sleep(pub.sleepOnIdleInterval)
-				}
+	go func() {
+		for stop := range pub.autoStop {
+			if stop {
 				break
 			}
 		}
 
+		close(stop)
 		pub.autoPublishGroup.Wait() // let all remaining publishes finish.
 
 		pub.pubLock.Lock()
@@ -169,6 +396,87 @@ func (pub *Publisher) StartAutoPublish(allowRetry bool) {
 	pub.pubLock.Unlock()
 }
 
+// PublishWorker publishes letters until stopped (or its letters channel is closed) while holding on to a ChannelHost.
+func (pub *Publisher) publishWorker(letters <-chan *queuedLetter, stop <-chan struct{}, allowRetry bool) {
+	defer pub.autoPublishGroup.Done()
+
+	var chanHost *pools.ChannelHost
+	defer func() {
+		if chanHost != nil {
+			pub.ChannelPool.ReturnChannel(chanHost, false)
+		}
+	}()
+
+	attempts := func(letter *models.Letter) uint32 {
+		if allowRetry {
+			return letter.RetryCount + 1
+		}
+		return 1
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case queued, ok := <-letters:
+			if !ok {
+				return
+			}
+
+			atomic.AddInt32(&pub.busyWorkers, 1)
+			pub.stats.queueWait.observe(time.Since(queued.queuedAt))
+
+			letter := queued.letter
+
+			if letter.Expired() {
+				pub.middleware.runAfter(letter, pub.dropExpiredLetter(letter), 0)
+			} else {
+				if pub.rateLimiter != nil {
+					pub.rateLimiter.wait(letter)
+				}
+
+				// The held channel may have closed while the worker was idle.
+				if chanHost != nil {
+					select {
+					case <-chanHost.CloseErrors():
+						pub.ChannelPool.ReturnChannel(chanHost, true)
+						chanHost = nil
+					default:
+					}
+				}
+
+				chanHost = pub.publishAttempts(chanHost, letter, attempts(letter))
+			}
+
+			atomic.AddInt32(&pub.busyWorkers, -1)
+			pub.reduceLetterCount()
+		}
+	}
+}
+
+// WorkerStats lets you know how saturated the AutoPublish workers are.
+func (pub *Publisher) WorkerStats() *models.WorkerStats {
+	pub.pubLock.Lock()
+	workerCount := uint32(0)
+	if pub.autoStarted {
+		workerCount = pub.workerCount
+	}
+	pub.pubLock.Unlock()
+
+	stats := &models.WorkerStats{
+		WorkerCount:    workerCount,
+		BusyWorkers:    uint32(atomic.LoadInt32(&pub.busyWorkers)),
+		QueuedLetters:  pub.letterCountSnapshot(),
+		SaturatedQueue: atomic.LoadUint64(&pub.saturatedQueue),
+	}
+
+	if stats.WorkerCount > 0 {
+		stats.Saturation = float64(stats.BusyWorkers) / float64(stats.WorkerCount)
+	}
+
+	return stats
+}
+
 // StopAutoPublish stops publishing letters queued up - is locking.
 func (pub *Publisher) StopAutoPublish() {
 	pub.pubLock.Lock()
@@ -178,7 +486,11 @@ func (pub *Publisher) StopAutoPublish() {
 		return
 	}
 
-	go func() { pub.autoStop <- true }() // signal auto publish to stop
+	// Signal auto publish to stop, a stop that is already pending is good enough.
+	select {
+	case pub.autoStop <- true:
+	default:
+	}
 }
 
 // QueueLetters allows you to bulk queue letters that will be consumed by AutoPublish.
@@ -186,12 +498,6 @@ func (pub *Publisher) StopAutoPublish() {
 func (pub *Publisher) QueueLetters(letters []*models.Letter) {
 
 	for i := 0; i < len(letters); i++ {
-		// Loop here until (buffer + maxOverBuffer) has room for you.
-		for atomic.LoadUint64(&pub.letterCount) >= (pub.letterBuffer + pub.maxOverBuffer) {
-			time.Sleep(pub.sleepOnQueueFullInterval)
-			continue
-		}
-
 		pub.queueLetter(letters[i])
 	}
 }
@@ -199,67 +505,216 @@ func (pub *Publisher) QueueLetters(letters []*models.Letter) {
 // QueueLetter queues up a letter that will be consumed by AutoPublish.
 // Blocks on the Letter Buffer being full.
 func (pub *Publisher) QueueLetter(letter *models.Letter) {
-
-	// Loop here until (buffer + maxOverBuffer) has room for you.
-	for atomic.LoadUint64(&pub.letterCount) >= (pub.letterBuffer + pub.maxOverBuffer) {
-		time.Sleep(pub.sleepOnQueueFullInterval)
-		continue
-	}
-
 	pub.queueLetter(letter)
 }
 
 func (pub *Publisher) queueLetter(letter *models.Letter) {
+	if !pub.acceptLetter(letter) {
+		return
+	}
+
+	if atomic.LoadInt32(&pub.busyWorkers) >= int32(pub.workerCount) {
+		atomic.AddUint64(&pub.saturatedQueue, 1)
+	}
+
 	pub.increaseLetterCount()
-	pub.letters <- letter
+	atomic.AddUint64(&pub.stats.queued, 1)
+	pub.letters <- &queuedLetter{letter: letter, queuedAt: time.Now()}
 }
 
-// IncreaseLetterCount decreases internal letter count - used to minimize outage CPU/Mem spin up on a blocked channel.
+// IncreaseLetterCount increases internal letter count, waiting until (buffer + maxOverBuffer) has room.
+// Used to minimize outage CPU/Mem spin up on a blocked channel.
 func (pub *Publisher) increaseLetterCount() {
 	pub.pubRWLock.Lock()
+	for pub.letterCount >= pub.letterBuffer+pub.maxOverBuffer {
+		pub.queueRoom.Wait() // signaled by reduceLetterCount
+	}
+
 	pub.letterCount++
 	pub.pubRWLock.Unlock()
 }
 
-// ReduceLetterCount decreases internal letter count - used to minimize outage CPU/Mem spin up on a blocked channel.
+// ReduceLetterCount decreases internal letter count and wakes up a caller waiting on room in the queue.
 func (pub *Publisher) reduceLetterCount() {
 	pub.pubRWLock.Lock()
 	pub.letterCount--
 	pub.pubRWLock.Unlock()
+
+	pub.queueRoom.Signal()
+}
+
+// LetterCountSnapshot lets you know how many letters are queued or being published by AutoPublish.
+func (pub *Publisher) letterCountSnapshot() uint64 {
+	pub.pubRWLock.RLock()
+	defer pub.pubRWLock.RUnlock()
+
+	return pub.letterCount
+}
+
+// PublishLetter publishes the letter, in chunks when its Body is larger than the ChunkSize.
+// Every chunk is published again on a retry, consumers keep the first copy of each chunk.
+func (pub *Publisher) publishLetter(amqpChan *amqp.Channel, letter *models.Letter) error {
+
+	total := pub.chunkCount(letter)
+	if total == 1 {
+		return pub.simplePublish(amqpChan, letter)
+	}
+
+	for seq := uint64(0); seq < total; seq++ {
+		if err := pub.simplePublish(amqpChan, chunkOf(letter, seq, total, pub.chunkSize)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ChunkCount gets how many messages publishLetter publishes for the letter.
+func (pub *Publisher) chunkCount(letter *models.Letter) uint64 {
+
+	if pub.chunkSize == 0 || uint64(len(letter.Body)) <= pub.chunkSize {
+		return 1
+	}
+
+	return (uint64(len(letter.Body)) + pub.chunkSize - 1) / pub.chunkSize
+}
+
+// ChunkOf copies the letter with only the seq chunk of its Body and the chunk headers.
+func chunkOf(letter *models.Letter, seq uint64, total uint64, chunkSize uint64) *models.Letter {
+
+	end := (seq + 1) * chunkSize
+	if end > uint64(len(letter.Body)) {
+		end = uint64(len(letter.Body))
+	}
+
+	chunk := *letter
+	envelope := *letter.Envelope
+	chunk.Envelope = &envelope
+	chunk.Body = letter.Body[seq*chunkSize : end]
+
+	envelope.Headers = make(map[string]interface{}, len(letter.Envelope.Headers)+3)
+	for key, value := range letter.Envelope.Headers {
+		envelope.Headers[key] = value
+	}
+
+	envelope.Headers[models.ChunkGroupHeader] = letter.MessageID
+	envelope.Headers[models.ChunkSeqHeader] = int64(seq)
+	envelope.Headers[models.ChunkTotalHeader] = int64(total)
+
+	return &chunk
 }
 
 // SimplePublish performs the actual amqp.Publish.
+// A letter's remaining time until its Deadline becomes the message expiration.
 func (pub *Publisher) simplePublish(amqpChan *amqp.Channel, letter *models.Letter) error {
 
+	publishing := amqp.Publishing{
+		ContentType:  letter.Envelope.ContentType,
+		Body:         letter.Body,
+		Headers:      amqp.Table(letter.Envelope.Headers),
+		DeliveryMode: letter.Envelope.DeliveryMode,
+		MessageId:    letter.MessageID,
+	}
+
+	if !letter.Deadline.IsZero() {
+		publishing.Expiration = strconv.FormatInt(int64(letter.TimeRemaining()/time.Millisecond), 10)
+	}
+
 	return amqpChan.Publish(
 		letter.Envelope.Exchange,
 		letter.Envelope.RoutingKey,
 		letter.Envelope.Mandatory,
 		letter.Envelope.Immediate,
-		amqp.Publishing{
-			ContentType:  letter.Envelope.ContentType,
-			Body:         letter.Body,
-			Headers:      amqp.Table(letter.Envelope.Headers),
-			DeliveryMode: letter.Envelope.DeliveryMode,
-		},
+		publishing,
 	)
 }
 
-// SendToNotifications sends the status to the notifications channel.
-func (pub *Publisher) sendToNotifications(letter *models.Letter, err error) {
+// AcceptLetter gives the letter a MessageID when it doesn't have one and claims it in the dedupe window.
+// Returns false, after reporting the letter as failed with the FailureDuplicate reason, when it is a duplicate.
+func (pub *Publisher) acceptLetter(letter *models.Letter) bool {
+	return pub.admitLetter(letter) == nil
+}
+
+// AdmitLetter is acceptLetter returning the Notification sent for a duplicate letter, nil when the letter is accepted.
+func (pub *Publisher) admitLetter(letter *models.Letter) *models.Notification {
+
+	if letter.MessageID == "" {
+		letter.MessageID = pub.messageIDGenerator.Load().(func() string)()
+	}
+
+	if pub.dedupe != nil && !pub.dedupe.claim(letter.MessageID, time.Now()) {
+		atomic.AddUint64(&pub.stats.dropped, 1)
+		return pub.sendFailureToNotifications(
+			letter,
+			models.FailureDuplicate,
+			fmt.Errorf("letter with MessageID %s was already submitted", letter.MessageID))
+	}
+
+	return nil
+}
+
+// ReleaseLetter lets a letter that failed to publish be submitted again within the dedupe window.
+func (pub *Publisher) releaseLetter(letter *models.Letter) {
+	if pub.dedupe != nil {
+		pub.dedupe.release(letter.MessageID)
+	}
+}
+
+// SetMessageIDGenerator replaces the generator used to give letters without a MessageID one.
+func (pub *Publisher) SetMessageIDGenerator(generator func() string) {
+	if generator == nil {
+		return
+	}
+
+	pub.messageIDGenerator.Store(generator)
+}
+
+// DropExpiredLetter reports the letter as failed with the FailureExpired reason and returns the error reported.
+func (pub *Publisher) dropExpiredLetter(letter *models.Letter) error {
+	return pub.expireLetter(letter).Error
+}
+
+// ExpireLetter is dropExpiredLetter returning the Notification sent.
+func (pub *Publisher) expireLetter(letter *models.Letter) *models.Notification {
+	err := fmt.Errorf("letter expired at %s before it could be published", letter.Deadline.Format(time.RFC3339Nano))
+
+	atomic.AddUint64(&pub.stats.dropped, 1)
+	pub.releaseLetter(letter)
+	return pub.sendFailureToNotifications(letter, models.FailureExpired, err)
+}
+
+// SendToNotifications sends the status to the notifications channel and returns the Notification sent.
+func (pub *Publisher) sendToNotifications(letter *models.Letter, err error) *models.Notification {
+
+	if err != nil {
+		return pub.sendFailureToNotifications(letter, models.FailurePublish, err)
+	}
 
 	notification := &models.Notification{
 		LetterID: letter.LetterID,
-		Error:    err,
+		Success:  true,
 	}
 
-	if err == nil {
-		notification.Success = true
-	} else {
-		notification.FailedLetter = letter
+	pub.notificationDispatch.Dispatch(notification)
+	return notification
+}
+
+// SendFailureToNotifications sends a failed status, and why it failed, to the notifications channel and returns the
+// Notification sent.
+func (pub *Publisher) sendFailureToNotifications(
+	letter *models.Letter,
+	reason models.FailureReason,
+	err error) *models.Notification {
+
+	notification := &models.Notification{
+		LetterID:     letter.LetterID,
+		FailedLetter: letter,
+		Reason:       reason,
+		Error:        err,
 	}
 
-	go func() { pub.notifications <- notification }()
+	pub.notificationDispatch.Dispatch(notification)
+	return notification
 }
 
 // AutoPublishStarted allows you to see if the AutoPublish feature has started - is locking.
@@ -286,6 +741,9 @@ FlushLoop:
 // Shutdown cleanly shutsdown the publisher and resets it's internal state.
 func (pub *Publisher) Shutdown(shutdownPools bool) {
 	pub.StopAutoPublish()
+	pub.cancelScheduledLetters()
+	pub.async.close()
+	pub.closeTxChannel()
 
 	if shutdownPools { // in case the ChannelPool is shared between structs, you can prevent it from shuttingdown
 		pub.ChannelPool.Shutdown()
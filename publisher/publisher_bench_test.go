@@ -19,6 +19,7 @@ type TestStruct struct {
 
 func BenchmarkAutoPublishRandomLetters(b *testing.B) {
 	b.ReportAllocs()
+	requireBroker(b)
 
 	testQueuePrefix := "PubTQ"
 	b.Logf("%s: Purging Queues...", time.Now())
@@ -80,6 +81,7 @@ func BenchmarkAutoPublishRandomLetters(b *testing.B) {
 
 func BenchmarkAutoPublishRandomEncryptedLetters(b *testing.B) {
 	b.ReportAllocs()
+	requireBroker(b)
 
 	testQueuePrefix := "PubTQ"
 	b.Logf("%s: Purging Queues...", time.Now())
@@ -198,3 +200,74 @@ func setupEncryption() (*models.EncryptionConfig, *models.CompressionConfig, *Te
 
 	return encrypt, compression, test
 }
+
+// BenchmarkAutoPublishLatency measures the time from queueing a single letter to receiving its notification.
+// End to end through RabbitMQ, BenchmarkQueueLetterWaitingOnRoom compares the former sleep/poll loop without it.
+func BenchmarkAutoPublishLatency(b *testing.B) {
+	b.ReportAllocs()
+	requireBroker(b)
+
+	testQueuePrefix := "PubTQ"
+	purgeAllPublisherTestQueues(testQueuePrefix, ChannelPool)
+
+	pub, err := publisher.NewPublisher(Seasoning, ChannelPool, ConnectionPool)
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	pub.StartAutoPublish(false)
+	letter := utils.CreateMockLetter(1, "", fmt.Sprintf("%s-%d", testQueuePrefix, 0), nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pub.QueueLetter(letter)
+		<-pub.Notifications()
+	}
+	b.StopTimer()
+
+	pub.StopAutoPublish()
+	purgeAllPublisherTestQueues(testQueuePrefix, ChannelPool)
+}
+
+// BenchmarkQueueLetterWhenFull measures QueueLetter throughput when callers are always waiting on a full LetterBuffer.
+// BenchmarkQueueLetterWaitingOnRoom compares sleeping on SleepOnQueueFullInterval with being signaled without RabbitMQ.
+func BenchmarkQueueLetterWhenFull(b *testing.B) {
+	b.ReportAllocs()
+	requireBroker(b)
+
+	testQueuePrefix := "PubTQ"
+	purgeAllPublisherTestQueues(testQueuePrefix, ChannelPool)
+
+	publisherConfig := *Seasoning.PublisherConfig
+	publisherConfig.LetterBuffer = 10
+	publisherConfig.MaxOverBuffer = 0
+
+	config := *Seasoning
+	config.PublisherConfig = &publisherConfig
+
+	pub, err := publisher.NewPublisher(&config, ChannelPool, ConnectionPool)
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	done := make(chan bool, 1)
+	go func() {
+		for i := 0; i < b.N; i++ {
+			<-pub.Notifications()
+		}
+		done <- true
+	}()
+
+	pub.StartAutoPublish(false)
+	letter := utils.CreateMockLetter(1, "", fmt.Sprintf("%s-%d", testQueuePrefix, 0), nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pub.QueueLetter(letter)
+	}
+	<-done
+	b.StopTimer()
+
+	pub.StopAutoPublish()
+	purgeAllPublisherTestQueues(testQueuePrefix, ChannelPool)
+}
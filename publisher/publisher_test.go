@@ -1,8 +1,13 @@
 package publisher_test
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -31,19 +36,58 @@ func TestMain(m *testing.M) { // Load Configuration On Startup
 	ConnectionPool, err = pools.NewConnectionPool(Seasoning.PoolConfig, true)
 	if err != nil {
 		fmt.Print(err.Error())
-		return
+		os.Exit(m.Run()) // tests needing RabbitMQ skip themselves
 	}
 
 	ChannelPool, err = pools.NewChannelPool(Seasoning.PoolConfig, ConnectionPool, true)
 	if err != nil {
 		fmt.Print(err.Error())
-		return
+		os.Exit(m.Run())
 	}
 
 	os.Exit(m.Run())
 }
 
+// RequireBroker skips a test needing RabbitMQ when TestMain couldn't connect to it.
+func requireBroker(tb testing.TB) {
+	if ChannelPool == nil {
+		tb.Skip("RabbitMQ isn't reachable")
+	}
+}
+
+func TestNewPublisherWorkerCount(t *testing.T) {
+
+	tests := []struct {
+		workerCount uint32
+		maxChannels uint64
+		expected    uint32
+	}{
+		{workerCount: 3, maxChannels: 4, expected: 3},
+		{workerCount: 4, maxChannels: 4, expected: 3}, // leaves a channel
+		{workerCount: 9, maxChannels: 4, expected: 3},
+		{workerCount: 2, maxChannels: 1, expected: 1}, // always one worker
+		{workerCount: 0, maxChannels: 1, expected: 1},
+		{workerCount: 0, maxChannels: 0, expected: 1},
+	}
+
+	for _, test := range tests {
+		config := &models.RabbitSeasoning{PublisherConfig: &models.PublisherConfig{PublishWorkerCount: test.workerCount}}
+		channelPool := &pools.ChannelPool{
+			Config: models.PoolConfig{ChannelPoolConfig: &models.ChannelPoolConfig{MaxChannelCount: test.maxChannels}},
+		}
+
+		pub, err := publisher.NewPublisher(config, channelPool, nil)
+		assert.NoError(t, err)
+
+		pub.StartAutoPublish(false) // idle workers don't get a channel
+		assert.Equal(t, test.expected, pub.WorkerStats().WorkerCount, "%d workers, %d channels", test.workerCount, test.maxChannels)
+		pub.StopAutoPublish()
+	}
+}
+
 func TestCreatePublisher(t *testing.T) {
+	requireBroker(t)
+
 	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
 	assert.NoError(t, err)
 
@@ -55,6 +99,8 @@ func TestCreatePublisher(t *testing.T) {
 }
 
 func TestCreatePublisherAndPublish(t *testing.T) {
+	requireBroker(t)
+
 	defer leaktest.Check(t)() // Fail on leaked goroutines.
 
 	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
@@ -106,6 +152,7 @@ AssertLoop:
 }
 
 func TestAutoPublishSingleMessage(t *testing.T) {
+	requireBroker(t)
 
 	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
 	assert.NoError(t, err)
@@ -144,6 +191,7 @@ AssertLoop:
 }
 
 func TestAutoPublishManyMessages(t *testing.T) {
+	requireBroker(t)
 
 	defer leaktest.Check(t)() // Fail on leaked goroutines.
 	messageCount := 100000
@@ -230,6 +278,8 @@ ListeningForNotificationsLoop:
 }
 
 func TestTwoAutoPublishSameChannelPool(t *testing.T) {
+	requireBroker(t)
+
 	defer leaktest.Check(t)() // Fail on leaked goroutines.
 
 	messageCount := 50000
@@ -326,6 +376,8 @@ ListeningForNotificationsLoop:
 }
 
 func TestFourAutoPublishSameChannelPool(t *testing.T) {
+	requireBroker(t)
+
 	defer leaktest.Check(t)() // Fail on leaked goroutines.
 
 	messageCount := 50000
@@ -439,6 +491,8 @@ ListeningForNotificationsLoop:
 }
 
 func TestFourAutoPublishFourChannelPool(t *testing.T) {
+	requireBroker(t)
+
 	defer leaktest.Check(t)() // Fail on leaked goroutines.
 
 	messageCount := 50000
@@ -560,6 +614,249 @@ ListeningForNotificationsLoop:
 	channelPool4.Shutdown()
 }
 
+func TestPublishWithRateLimit(t *testing.T) {
+	requireBroker(t)
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	publisherConfig := *Seasoning.PublisherConfig
+	publisherConfig.RateLimitConfig = &models.RateLimitConfig{
+		Enabled: true,
+		DestinationRates: []*models.DestinationRateLimit{
+			{Exchange: "", RoutingKey: "ConsumerTestQueue", Rate: 10, Burst: 1},
+		},
+	}
+
+	config := *Seasoning
+	config.PublisherConfig = &publisherConfig
+
+	publisher, err := publisher.NewPublisher(&config, channelPool, nil)
+	assert.NoError(t, err)
+
+	messageCount := 5
+	timeStart := time.Now()
+	for i := 0; i < messageCount; i++ {
+		publisher.Publish(utils.CreateMockRandomLetter("ConsumerTestQueue"))
+	}
+
+	// First letter uses the burst, the remaining four wait ~100ms each.
+	assert.True(t, time.Since(timeStart) >= 350*time.Millisecond)
+
+	successCount := 0
+	timer := time.NewTimer(10 * time.Second)
+
+AssertLoop:
+	for successCount < messageCount {
+		select {
+		case <-timer.C:
+			break AssertLoop
+		case notification := <-publisher.Notifications():
+			assert.True(t, notification.Success)
+			successCount++
+		}
+	}
+
+	assert.Equal(t, messageCount, successCount)
+
+	channelPool.Shutdown()
+}
+
+func TestTryPublishWithRateLimit(t *testing.T) {
+	requireBroker(t)
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	publisherConfig := *Seasoning.PublisherConfig
+	publisherConfig.RateLimitConfig = &models.RateLimitConfig{
+		Enabled:     true,
+		GlobalRate:  1,
+		GlobalBurst: 1,
+	}
+
+	config := *Seasoning
+	config.PublisherConfig = &publisherConfig
+
+	publisher, err := publisher.NewPublisher(&config, channelPool, nil)
+	assert.NoError(t, err)
+
+	assert.True(t, publisher.TryPublish(utils.CreateMockRandomLetter("ConsumerTestQueue")))
+	assert.False(t, publisher.TryPublish(utils.CreateMockRandomLetter("ConsumerTestQueue")))
+
+	notification := <-publisher.Notifications()
+	assert.True(t, notification.Success)
+
+	channelPool.Shutdown()
+}
+
+func TestPublishExpiredLetter(t *testing.T) {
+	requireBroker(t)
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	publisher, err := publisher.NewPublisher(Seasoning, channelPool, nil)
+	assert.NoError(t, err)
+
+	letter := utils.CreateMockRandomLetter("ConsumerTestQueue")
+	letter.RetryCount = 3
+	letter.Deadline = time.Now().Add(-1 * time.Second)
+
+	publisher.PublishWithRetry(letter)
+
+	notification := <-publisher.Notifications()
+	assert.False(t, notification.Success)
+	assert.Equal(t, models.FailureExpired, notification.Reason)
+	assert.Equal(t, letter, notification.FailedLetter)
+	assert.Error(t, notification.Error)
+
+	// Only one notification, the retries are skipped.
+	select {
+	case <-publisher.Notifications():
+		assert.Fail(t, "expected a single notification for an expired letter")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	channelPool.Shutdown()
+}
+
+func TestAutoPublishLetterWithTTL(t *testing.T) {
+	requireBroker(t)
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	publisher, err := publisher.NewPublisher(Seasoning, channelPool, nil)
+	assert.NoError(t, err)
+
+	letter := utils.CreateMockRandomLetter("ConsumerTestQueue")
+	letter.SetTTL(1 * time.Minute)
+
+	publisher.StartAutoPublish(false)
+	publisher.QueueLetter(letter)
+
+	notification := <-publisher.Notifications()
+	assert.True(t, notification.Success)
+	assert.Equal(t, models.FailureNone, notification.Reason)
+
+	publisher.StopAutoPublish()
+	channelPool.Shutdown()
+}
+
+func TestOrderedAutoPublish(t *testing.T) {
+	requireBroker(t)
+
+	defer leaktest.Check(t)() // Fail on leaked goroutines.
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	// Purge all queues first.
+	purgeAllPublisherTestQueues("PubTQ", channelPool)
+
+	publisherConfig := *Seasoning.PublisherConfig
+	publisherConfig.OrderedAutoPublish = true
+
+	config := *Seasoning
+	config.PublisherConfig = &publisherConfig
+
+	publisher, err := publisher.NewPublisher(&config, channelPool, nil)
+	assert.NoError(t, err)
+
+	messageCount := 1000
+	publisher.StartAutoPublish(false)
+
+	for i := 0; i < messageCount; i++ {
+		publisher.QueueLetter(utils.CreateMockLetter(uint64(i), "", "PubTQ-0", []byte(fmt.Sprintf("%d", i))))
+	}
+
+	for i := 0; i < messageCount; i++ {
+		notification := <-publisher.Notifications()
+		assert.True(t, notification.Success)
+	}
+
+	publisher.StopAutoPublish()
+
+	chanHost, err := channelPool.GetChannel()
+	assert.NoError(t, err)
+
+	for i := 0; i < messageCount; i++ {
+		delivery, ok, err := chanHost.Channel.Get("PubTQ-0", true)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, fmt.Sprintf("%d", i), string(delivery.Body))
+	}
+
+	channelPool.ReturnChannel(chanHost, false)
+
+	// Purge all queues.
+	purgeAllPublisherTestQueues("PubTQ", channelPool)
+
+	channelPool.Shutdown()
+}
+
+func TestAutoPublishWorkerStats(t *testing.T) {
+	requireBroker(t)
+
+	defer leaktest.Check(t)() // Fail on leaked goroutines.
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	publisherConfig := *Seasoning.PublisherConfig
+	publisherConfig.PublishWorkerCount = 2
+
+	config := *Seasoning
+	config.PublisherConfig = &publisherConfig
+
+	publisher, err := publisher.NewPublisher(&config, channelPool, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, uint32(0), publisher.WorkerStats().WorkerCount)
+
+	publisher.StartAutoPublish(false)
+
+	messageCount := 100
+	for i := 0; i < messageCount; i++ {
+		publisher.QueueLetter(utils.CreateMockRandomLetter("ConsumerTestQueue"))
+	}
+
+	stats := publisher.WorkerStats()
+	assert.Equal(t, uint32(2), stats.WorkerCount)
+	assert.True(t, stats.BusyWorkers <= stats.WorkerCount)
+
+	for i := 0; i < messageCount; i++ {
+		notification := <-publisher.Notifications()
+		assert.True(t, notification.Success)
+	}
+
+	publisher.StopAutoPublish()
+
+	for publisher.AutoPublishStarted() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats = publisher.WorkerStats()
+	assert.Equal(t, uint32(0), stats.WorkerCount)
+	assert.Equal(t, uint32(0), stats.BusyWorkers)
+	assert.Equal(t, uint64(0), stats.QueuedLetters)
+
+	channelPool.Shutdown()
+}
+
 func purgeAllPublisherTestQueues(queuePrefix string, channelPool *pools.ChannelPool) {
 	topologer, err := topology.NewTopologer(channelPool)
 	if err == nil {
@@ -570,3 +867,385 @@ func purgeAllPublisherTestQueues(queuePrefix string, channelPool *pools.ChannelP
 		}
 	}
 }
+
+func TestPublishDuplicateLetter(t *testing.T) {
+	requireBroker(t)
+
+	publisherConfig := *Seasoning.PublisherConfig
+	publisherConfig.DedupeWindow = 1000
+	config := *Seasoning
+	config.PublisherConfig = &publisherConfig
+
+	channelPool, err := pools.NewChannelPool(config.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	publisher, err := publisher.NewPublisher(&config, channelPool, nil)
+	assert.NoError(t, err)
+
+	letter := utils.CreateMockRandomLetter("ConsumerTestQueue")
+	assert.Equal(t, "", letter.MessageID)
+
+	publisher.PublishWithRetry(letter)
+	assert.NotEqual(t, "", letter.MessageID)
+
+	notification := <-publisher.Notifications()
+	assert.True(t, notification.Success)
+
+	// Same logical letter (same MessageID) within the window is suppressed.
+	duplicate := *letter
+	publisher.Publish(&duplicate)
+
+	notification = <-publisher.Notifications()
+	assert.False(t, notification.Success)
+	assert.Equal(t, models.FailureDuplicate, notification.Reason)
+
+	// A custom generator is used for letters without a MessageID.
+	publisher.SetMessageIDGenerator(func() string { return "CustomMessageID" })
+
+	letter = utils.CreateMockRandomLetter("ConsumerTestQueue")
+	publisher.Publish(letter)
+	assert.Equal(t, "CustomMessageID", letter.MessageID)
+
+	notification = <-publisher.Notifications()
+	assert.True(t, notification.Success)
+
+	channelPool.Shutdown()
+}
+
+func TestPublishWithMiddleware(t *testing.T) {
+	requireBroker(t)
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	publisher, err := publisher.NewPublisher(Seasoning, channelPool, nil)
+	assert.NoError(t, err)
+
+	publisher.UseBeforePublish(
+		func(letter *models.Letter) error {
+			if len(letter.Body) == 0 {
+				return errors.New("letter has no body")
+			}
+			return nil
+		},
+		func(letter *models.Letter) error {
+			if letter.Envelope.Headers == nil {
+				letter.Envelope.Headers = make(map[string]interface{})
+			}
+			letter.Envelope.Headers["x-trace-id"] = letter.MessageID
+			return nil
+		})
+
+	results := make(chan error, 2)
+	publisher.UseAfterPublish(func(letter *models.Letter, err error, duration time.Duration) {
+		assert.True(t, duration >= 0)
+		results <- err
+	})
+
+	letter := utils.CreateMockRandomLetter("ConsumerTestQueue")
+	publisher.PublishWithRetry(letter)
+
+	assert.NoError(t, <-results)
+	assert.Equal(t, letter.MessageID, letter.Envelope.Headers["x-trace-id"])
+	assert.True(t, (<-publisher.Notifications()).Success)
+
+	emptyLetter := utils.CreateMockRandomLetter("ConsumerTestQueue")
+	emptyLetter.Body = nil
+	publisher.Publish(emptyLetter)
+
+	assert.Error(t, <-results)
+	notification := <-publisher.Notifications()
+	assert.False(t, notification.Success)
+	assert.Equal(t, models.FailureRejected, notification.Reason)
+
+	channelPool.Shutdown()
+}
+
+func TestPublishAfterLocally(t *testing.T) {
+	requireBroker(t)
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	publisher, err := publisher.NewPublisher(Seasoning, channelPool, nil)
+	assert.NoError(t, err)
+
+	letter := utils.CreateMockRandomLetter("ConsumerTestQueue")
+
+	start := time.Now()
+	publisher.PublishAfter(letter, 200*time.Millisecond)
+
+	notification := <-publisher.Notifications()
+	assert.True(t, notification.Success)
+	assert.True(t, time.Since(start) >= 200*time.Millisecond)
+
+	// Letters still scheduled when the Publisher shuts down are reported as failed.
+	publisher.PublishAfter(utils.CreateMockRandomLetter("ConsumerTestQueue"), time.Minute)
+	publisher.Shutdown(false)
+
+	notification = <-publisher.Notifications()
+	assert.False(t, notification.Success)
+
+	channelPool.Shutdown()
+}
+
+func TestPublishAtWithWaitQueue(t *testing.T) {
+	requireBroker(t)
+
+	publisherConfig := *Seasoning.PublisherConfig
+	publisherConfig.DelayConfig = &models.DelayConfig{
+		Strategy:        models.DelayWaitQueue,
+		WaitQueuePrefix: "TurboCookedRabbit.Wait",
+	}
+	config := *Seasoning
+	config.PublisherConfig = &publisherConfig
+
+	channelPool, err := pools.NewChannelPool(config.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	topologer, err := topology.NewTopologer(channelPool)
+	assert.NoError(t, err)
+
+	err = topologer.CreateQueue("PubDelayTQ", false, true, false, false, false, nil)
+	assert.NoError(t, err)
+
+	_, err = topologer.PurgeQueue("PubDelayTQ", false)
+	assert.NoError(t, err)
+
+	publisher, err := publisher.NewPublisher(&config, channelPool, nil)
+	assert.NoError(t, err)
+
+	letter := utils.CreateMockRandomLetter("PubDelayTQ")
+	publisher.PublishAt(letter, time.Now().Add(time.Second))
+
+	notification := <-publisher.Notifications()
+	assert.True(t, notification.Success)
+
+	chanHost, err := channelPool.GetChannel()
+	assert.NoError(t, err)
+
+	// Parked in the wait queue until the TTL dead-letters it.
+	_, ok, err := chanHost.Channel.Get("PubDelayTQ", true)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	time.Sleep(1500 * time.Millisecond)
+
+	delivery, ok, err := chanHost.Channel.Get("PubDelayTQ", true)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, letter.MessageID, delivery.MessageId)
+
+	channelPool.ReturnChannel(chanHost, false)
+	channelPool.Shutdown()
+}
+
+func TestPublishWithRetryToFailureSink(t *testing.T) {
+	requireBroker(t)
+
+	sinkFile := filepath.Join(os.TempDir(), "TurboCookedRabbitFailedLetters.jsonl")
+	os.Remove(sinkFile)
+	defer os.Remove(sinkFile)
+
+	publisherConfig := *Seasoning.PublisherConfig
+	publisherConfig.FailureSinkConfig = &models.FailureSinkConfig{
+		Exchange: "FailedLetters",
+		FilePath: sinkFile,
+	}
+	config := *Seasoning
+	config.PublisherConfig = &publisherConfig
+
+	channelPool, err := pools.NewChannelPool(config.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	pub, err := publisher.NewPublisher(&config, channelPool, nil)
+	assert.NoError(t, err)
+
+	// Every attempt fails to get a channel, republishing fails too so the letter ends up in the file.
+	channelPool.Shutdown()
+
+	letter := utils.CreateMockRandomLetter("ConsumerTestQueue")
+	letter.RetryCount = 2
+	pub.PublishWithRetry(letter)
+
+	notification := <-pub.Notifications()
+	assert.False(t, notification.Success)
+	assert.Equal(t, models.FailurePublish, notification.Reason)
+
+	file, err := os.Open(sinkFile)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	assert.True(t, scanner.Scan())
+
+	record := &models.FailedLetterRecord{}
+	assert.NoError(t, json.Unmarshal(scanner.Bytes(), record))
+	assert.Equal(t, letter.MessageID, record.MessageID)
+	assert.Equal(t, "ConsumerTestQueue", record.RoutingKey)
+	assert.Equal(t, letter.Body, record.Body)
+	assert.Equal(t, models.FailurePublish.String(), record.Headers[publisher.FailureReasonHeader])
+	assert.Equal(t, float64(3), record.Headers[publisher.FailureAttemptsHeader])
+	assert.Equal(t, 3, len(record.Headers[publisher.FailureHistoryHeader].([]interface{})))
+	assert.False(t, scanner.Scan())
+}
+
+func TestPublishTransaction(t *testing.T) {
+	requireBroker(t)
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	topologer, err := topology.NewTopologer(channelPool)
+	assert.NoError(t, err)
+
+	err = topologer.CreateQueue("PubTxTQ", false, true, false, false, false, nil)
+	assert.NoError(t, err)
+
+	_, err = topologer.PurgeQueue("PubTxTQ", false)
+	assert.NoError(t, err)
+
+	pub, err := publisher.NewPublisher(Seasoning, channelPool, nil)
+	assert.NoError(t, err)
+
+	// Rolled back, nothing is delivered.
+	err = pub.Transaction(func(tx *publisher.Tx) error {
+		assert.NoError(t, tx.Publish(utils.CreateMockRandomLetter("PubTxTQ")))
+		assert.NoError(t, tx.Publish(utils.CreateMockRandomLetter("PubTxTQ")))
+		return errors.New("changed my mind")
+	})
+	assert.Error(t, err)
+
+	for i := 0; i < 2; i++ {
+		notification := <-pub.Notifications()
+		assert.False(t, notification.Success)
+	}
+
+	// Committed, both are delivered.
+	err = pub.Transaction(func(tx *publisher.Tx) error {
+		if err := tx.Publish(utils.CreateMockRandomLetter("PubTxTQ")); err != nil {
+			return err
+		}
+		return tx.Publish(utils.CreateMockRandomLetter("PubTxTQ"))
+	})
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		notification := <-pub.Notifications()
+		assert.True(t, notification.Success)
+	}
+
+	// Expired letters are reported right away and left out of the transaction.
+	expired := utils.CreateMockRandomLetter("PubTxTQ")
+	expired.Deadline = time.Now().Add(-time.Second)
+	err = pub.Transaction(func(tx *publisher.Tx) error {
+		assert.Error(t, tx.Publish(expired))
+		return nil
+	})
+	assert.NoError(t, err)
+
+	notification := <-pub.Notifications()
+	assert.False(t, notification.Success)
+
+	chanHost, err := channelPool.GetChannel()
+	assert.NoError(t, err)
+
+	queue, err := chanHost.Channel.QueueInspect("PubTxTQ")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, queue.Messages)
+
+	channelPool.ReturnChannel(chanHost, false)
+	pub.Shutdown(false)
+	channelPool.Shutdown()
+}
+
+func TestPublisherStats(t *testing.T) {
+	requireBroker(t)
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	publisher, err := publisher.NewPublisher(Seasoning, channelPool, nil)
+	assert.NoError(t, err)
+
+	publisher.Publish(utils.CreateMockRandomLetter("ConsumerTestQueue"))
+	<-publisher.Notifications()
+
+	expired := utils.CreateMockRandomLetter("ConsumerTestQueue")
+	expired.Deadline = time.Now().Add(-time.Second)
+	publisher.Publish(expired)
+	<-publisher.Notifications()
+
+	publisher.StartAutoPublish(false)
+	publisher.QueueLetter(utils.CreateMockRandomLetter("ConsumerTestQueue"))
+	<-publisher.Notifications()
+	publisher.StopAutoPublish()
+
+	stats := publisher.Stats()
+	assert.Equal(t, uint64(2), stats.Published)
+	assert.Equal(t, uint64(1), stats.Dropped)
+	assert.Equal(t, uint64(1), stats.Queued)
+	assert.Equal(t, uint64(0), stats.Failed)
+	assert.Equal(t, uint64(1), stats.QueueWait.Count)
+	assert.Equal(t, uint64(2), stats.Publish.Count)
+	assert.Equal(t, stats.Publish.Count, stats.Publish.Buckets[len(stats.Publish.Buckets)-1].Count)
+	assert.True(t, stats.ChannelCheckout.Count >= 2)
+	assert.True(t, stats.Publish.Mean() <= stats.Publish.Max)
+
+	channelPool.Shutdown()
+}
+
+func TestPublishAsync(t *testing.T) {
+	requireBroker(t)
+
+	channelPool, err := pools.NewChannelPool(Seasoning.PoolConfig, nil, true)
+	assert.NoError(t, err)
+
+	channelPool.FlushErrors()
+
+	pub, err := publisher.NewPublisher(Seasoning, channelPool, nil)
+	assert.NoError(t, err)
+
+	futures := make([]*publisher.PublishFuture, 0, 100)
+	for i := 0; i < 100; i++ {
+		futures = append(futures, pub.PublishAsync(utils.CreateMockRandomLetter("ConsumerTestQueue")))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, future := range futures {
+		assert.NoError(t, future.Wait(ctx))
+		assert.True(t, future.Notification().Success)
+
+		notification := <-pub.Notifications() // still sent to the global stream
+		assert.True(t, notification.Success)
+	}
+
+	// Unroutable mandatory letters come back returned.
+	letter := utils.CreateMockRandomLetter("NoSuchQueueForPublishAsync")
+	letter.Envelope.Mandatory = true
+
+	future := pub.PublishAsync(letter)
+	assert.Error(t, future.Wait(ctx))
+	assert.Equal(t, models.FailureReturned, future.Notification().Reason)
+	<-pub.Notifications()
+
+	assert.Equal(t, uint64(100), pub.Stats().Confirm.Count)
+
+	pub.Shutdown(false)
+	channelPool.Shutdown()
+}
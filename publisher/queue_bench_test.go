@@ -0,0 +1,66 @@
+package publisher
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// SleepPollingQueue is how QueueLetter used to wait on room in a full LetterBuffer: polling the letter count and
+// sleeping on the SleepOnQueueFullInterval in between. Kept as the baseline of BenchmarkQueueLetterWaitingOnRoom.
+type sleepPollingQueue struct {
+	letterCount   uint64
+	letterBuffer  uint64
+	sleepInterval time.Duration
+}
+
+func (queue *sleepPollingQueue) increaseLetterCount() {
+	for atomic.LoadUint64(&queue.letterCount) >= queue.letterBuffer {
+		time.Sleep(queue.sleepInterval)
+	}
+
+	atomic.AddUint64(&queue.letterCount, 1)
+}
+
+func (queue *sleepPollingQueue) reduceLetterCount() {
+	atomic.AddUint64(&queue.letterCount, ^uint64(0))
+}
+
+// BenchmarkQueueLetterWaitingOnRoom measures how long a letter queued on a full LetterBuffer waits for the letter
+// before it to be published, sleeping and polling (before) against being signaled by reduceLetterCount (now).
+func BenchmarkQueueLetterWaitingOnRoom(b *testing.B) {
+
+	b.Run("SleepPolling", func(b *testing.B) {
+		queue := &sleepPollingQueue{letterBuffer: 1, sleepInterval: time.Millisecond}
+		benchmarkQueueRoom(b, queue.increaseLetterCount, queue.reduceLetterCount)
+	})
+
+	b.Run("Signaled", func(b *testing.B) {
+		pub := &Publisher{letterBuffer: 1, pubRWLock: &sync.RWMutex{}}
+		pub.queueRoom = sync.NewCond(pub.pubRWLock)
+		benchmarkQueueRoom(b, pub.increaseLetterCount, pub.reduceLetterCount)
+	})
+}
+
+// BenchmarkQueueRoom queues b.N letters on a buffer of one while a worker "publishes" each letter handed to it.
+func benchmarkQueueRoom(b *testing.B, increase func(), reduce func()) {
+	b.ReportAllocs()
+
+	handed := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < b.N; i++ {
+			<-handed
+			reduce()
+		}
+		close(done)
+	}()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		increase()
+		handed <- struct{}{}
+	}
+	<-done
+}
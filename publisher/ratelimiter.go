@@ -0,0 +1,159 @@
+package publisher
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+)
+
+// TokenBucket refills at a fixed rate (tokens per second) up to its burst size.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	lock   *sync.Mutex
+}
+
+func newTokenBucket(rate float64, burst uint32) *tokenBucket {
+	if burst == 0 {
+		burst = 1
+	}
+
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		lock:   &sync.Mutex{},
+	}
+}
+
+func (tb *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(tb.last).Seconds(); elapsed > 0 {
+		tb.tokens = math.Min(tb.burst, tb.tokens+elapsed*tb.rate)
+		tb.last = now
+	}
+}
+
+// Reserve takes a token (going into debt if needed) and returns how long to wait before using it.
+func (tb *tokenBucket) reserve(now time.Time) time.Duration {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
+	tb.refill(now)
+	tb.tokens--
+	if tb.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+}
+
+// Take takes a token only when one is available right now.
+func (tb *tokenBucket) take(now time.Time) bool {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
+	tb.refill(now)
+	if tb.tokens < 1 {
+		return false
+	}
+
+	tb.tokens--
+	return true
+}
+
+// GiveBack returns a token acquired by take.
+func (tb *tokenBucket) giveBack() {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
+	tb.tokens = math.Min(tb.burst, tb.tokens+1)
+}
+
+type destination struct {
+	exchange   string
+	routingKey string
+}
+
+// RateLimiter enforces the global and per destination token buckets of a RateLimitConfig.
+type rateLimiter struct {
+	global       *tokenBucket
+	destinations map[destination]*tokenBucket
+}
+
+// NewRateLimiter builds a rateLimiter, returns nil when rate limiting is not enabled.
+func newRateLimiter(config *models.RateLimitConfig) *rateLimiter {
+	if config == nil || !config.Enabled {
+		return nil
+	}
+
+	rl := &rateLimiter{
+		destinations: make(map[destination]*tokenBucket),
+	}
+
+	if config.GlobalRate > 0 {
+		rl.global = newTokenBucket(config.GlobalRate, config.GlobalBurst)
+	}
+
+	for _, limit := range config.DestinationRates {
+		if limit == nil || limit.Rate <= 0 {
+			continue
+		}
+
+		rl.destinations[destination{exchange: limit.Exchange, routingKey: limit.RoutingKey}] = newTokenBucket(limit.Rate, limit.Burst)
+	}
+
+	return rl
+}
+
+// BucketFor finds the destination bucket for a letter, exact matches win over exchange wide limits.
+func (rl *rateLimiter) bucketFor(letter *models.Letter) *tokenBucket {
+	if bucket, ok := rl.destinations[destination{exchange: letter.Envelope.Exchange, routingKey: letter.Envelope.RoutingKey}]; ok {
+		return bucket
+	}
+
+	return rl.destinations[destination{exchange: letter.Envelope.Exchange}]
+}
+
+// Wait blocks until the letter is allowed through both the global and destination limits.
+func (rl *rateLimiter) wait(letter *models.Letter) {
+	now := time.Now()
+
+	var delay time.Duration
+	if rl.global != nil {
+		delay = rl.global.reserve(now)
+	}
+
+	if bucket := rl.bucketFor(letter); bucket != nil {
+		if bucketDelay := bucket.reserve(now); bucketDelay > delay {
+			delay = bucketDelay
+		}
+	}
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// Allow takes tokens for the letter without blocking and returns false when a limit has been reached.
+func (rl *rateLimiter) allow(letter *models.Letter) bool {
+	now := time.Now()
+
+	bucket := rl.bucketFor(letter)
+	if bucket != nil && !bucket.take(now) {
+		return false
+	}
+
+	if rl.global != nil && !rl.global.take(now) {
+		if bucket != nil {
+			bucket.giveBack()
+		}
+		return false
+	}
+
+	return true
+}
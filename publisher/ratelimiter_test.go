@@ -0,0 +1,122 @@
+package publisher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+)
+
+func TestTokenBucketReserve(t *testing.T) {
+
+	start := time.Now()
+	bucket := newTokenBucket(10, 2) // a token every 100ms
+	bucket.last = start
+
+	tests := []struct {
+		at    time.Duration
+		delay time.Duration
+	}{
+		{at: 0, delay: 0},                      // burst
+		{at: 0, delay: 0},                      // burst
+		{at: 0, delay: 100 * time.Millisecond}, // in debt
+		{at: 0, delay: 200 * time.Millisecond},
+		{at: 300 * time.Millisecond, delay: 0}, // the debt is paid back with one token to spare
+		{at: 300 * time.Millisecond, delay: 100 * time.Millisecond},
+		{at: time.Second, delay: 0},
+	}
+
+	for i, test := range tests {
+		assert.InDelta(t, test.delay, bucket.reserve(start.Add(test.at)), float64(time.Millisecond), "reserve %d", i)
+	}
+}
+
+func TestTokenBucketTakeAndGiveBack(t *testing.T) {
+
+	start := time.Now()
+	bucket := newTokenBucket(1, 0) // burst 0 is 1
+	bucket.last = start
+
+	assert.True(t, bucket.take(start))
+	assert.False(t, bucket.take(start))
+
+	bucket.giveBack()
+	assert.True(t, bucket.take(start))
+	assert.False(t, bucket.take(start.Add(500*time.Millisecond)))
+	assert.True(t, bucket.take(start.Add(time.Second)))
+
+	// Never refills past the burst.
+	bucket.giveBack()
+	bucket.giveBack()
+	assert.True(t, bucket.take(start.Add(time.Second)))
+	assert.False(t, bucket.take(start.Add(time.Second)))
+}
+
+func TestRateLimiterBuckets(t *testing.T) {
+
+	assert.Nil(t, newRateLimiter(nil))
+	assert.Nil(t, newRateLimiter(&models.RateLimitConfig{GlobalRate: 1}))
+
+	limiter := newRateLimiter(&models.RateLimitConfig{
+		Enabled: true,
+		DestinationRates: []*models.DestinationRateLimit{
+			{Exchange: "Exchange", Rate: 1, Burst: 1},
+			{Exchange: "Exchange", RoutingKey: "Key", Rate: 1, Burst: 2},
+			{Exchange: "Disabled", Rate: 0},
+			nil,
+		},
+	})
+
+	letter := func(exchange, routingKey string) *models.Letter {
+		return &models.Letter{Envelope: &models.Envelope{Exchange: exchange, RoutingKey: routingKey}}
+	}
+
+	tests := []struct {
+		letter *models.Letter
+		burst  float64
+	}{
+		{letter: letter("Exchange", "Key"), burst: 2},   // exact match
+		{letter: letter("Exchange", "Other"), burst: 1}, // exchange wide
+		{letter: letter("Disabled", ""), burst: 0},
+		{letter: letter("Unknown", "Key"), burst: 0},
+	}
+
+	for _, test := range tests {
+		bucket := limiter.bucketFor(test.letter)
+		if test.burst == 0 {
+			assert.Nil(t, bucket)
+			continue
+		}
+
+		assert.Equal(t, test.burst, bucket.burst)
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+
+	limiter := newRateLimiter(&models.RateLimitConfig{
+		Enabled:     true,
+		GlobalRate:  0.001,
+		GlobalBurst: 2,
+		DestinationRates: []*models.DestinationRateLimit{
+			{Exchange: "Exchange", Rate: 0.001, Burst: 1},
+		},
+	})
+
+	limited := &models.Letter{Envelope: &models.Envelope{Exchange: "Exchange"}}
+	other := &models.Letter{Envelope: &models.Envelope{Exchange: "Other"}}
+
+	assert.True(t, limiter.allow(limited))
+	assert.False(t, limiter.allow(limited)) // destination limit, the global token isn't taken
+	assert.True(t, limiter.allow(other))
+	assert.False(t, limiter.allow(other)) // global limit
+
+	// The destination token goes back when the global limit is hit.
+	limiter.global.giveBack()
+	limiter.destinations[destination{exchange: "Exchange"}].giveBack()
+	assert.True(t, limiter.allow(other))
+	assert.False(t, limiter.allow(limited))
+	assert.Equal(t, float64(1), limiter.destinations[destination{exchange: "Exchange"}].tokens)
+}
@@ -0,0 +1,235 @@
+package publisher
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+	"github.com/prom3t3us/turbocookedrabbit/topology"
+)
+
+const defaultWaitQueuePrefix = "turbocookedrabbit.wait"
+
+// DefaultWaitQueueTiers are the wait queue delays, in milliseconds, when the DelayConfig doesn't have WaitQueueTiers.
+var defaultWaitQueueTiers = []uint32{1000, 5000, 10000, 30000, 60000, 300000, 600000, 1800000, 3600000}
+
+// PublishAt publishes the letter so it is delivered at the time given, right away when that time has passed.
+// Delays up to the DelayConfig's LocalThreshold (every delay when there is no DelayConfig) are scheduled in-process
+// and are lost if the Publisher shuts down first. Longer delays are held by the broker using the DelayConfig's Strategy.
+// A WaitQueue holds the letter for the longest tier that fits the delay, the rest is waited in-process beforehand.
+// A letter whose Deadline comes before the time given is dropped as expired.
+// Subscribe to Notifications to see success and errors.
+func (pub *Publisher) PublishAt(letter *models.Letter, at time.Time) {
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		pub.PublishWithRetry(letter)
+		return
+	}
+
+	if !pub.acceptLetter(letter) {
+		return
+	}
+
+	if !letter.Deadline.IsZero() && letter.Deadline.Before(at) {
+		pub.dropExpiredLetter(letter)
+		return
+	}
+
+	if pub.delayConfig == nil || delay <= time.Duration(pub.delayConfig.LocalThreshold)*time.Millisecond {
+		pub.scheduleLocally(letter, delay, pub.publishWithRetry)
+		return
+	}
+
+	if pub.delayConfig.Strategy == models.DelayWaitQueue {
+		waitQueueDelay := time.Duration(pub.waitQueueDelay(int64(delay/time.Millisecond))) * time.Millisecond
+		if waitQueueDelay == 0 {
+			pub.scheduleLocally(letter, delay, pub.publishWithRetry)
+			return
+		}
+
+		if rest := delay - waitQueueDelay; rest > 0 {
+			pub.scheduleLocally(letter, rest, func(letter *models.Letter) { pub.publishDelayed(letter, waitQueueDelay) })
+			return
+		}
+	}
+
+	pub.publishDelayed(letter, delay)
+}
+
+// PublishDelayed publishes the letter, with retries, for the broker to hold on to for the delay.
+func (pub *Publisher) publishDelayed(letter *models.Letter, delay time.Duration) {
+
+	delayedLetter, err := pub.delayedLetter(letter, delay)
+	if err != nil {
+		pub.releaseLetter(letter)
+		pub.sendToNotifications(letter, err)
+		return
+	}
+
+	pub.publishWithRetry(delayedLetter)
+}
+
+// PublishAfter publishes the letter so it is delivered once the delay has passed. See PublishAt.
+func (pub *Publisher) PublishAfter(letter *models.Letter, delay time.Duration) {
+	pub.PublishAt(letter, time.Now().Add(delay))
+}
+
+// ScheduleLocally hands the letter to publish once the delay has passed.
+func (pub *Publisher) scheduleLocally(letter *models.Letter, delay time.Duration, publish func(*models.Letter)) {
+	pub.scheduleLock.Lock()
+	defer pub.scheduleLock.Unlock()
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		pub.scheduleLock.Lock()
+		_, scheduled := pub.scheduledLetters[timer]
+		delete(pub.scheduledLetters, timer)
+		pub.scheduleLock.Unlock()
+
+		if scheduled {
+			publish(letter)
+		}
+	})
+
+	pub.scheduledLetters[timer] = letter
+}
+
+// CancelScheduledLetters stops every letter scheduled in-process and reports them as failed.
+func (pub *Publisher) cancelScheduledLetters() {
+	pub.scheduleLock.Lock()
+	scheduledLetters := pub.scheduledLetters
+	pub.scheduledLetters = make(map[*time.Timer]*models.Letter)
+	pub.scheduleLock.Unlock()
+
+	for timer, letter := range scheduledLetters {
+		if timer.Stop() {
+			pub.releaseLetter(letter)
+			pub.sendToNotifications(letter, errors.New("publisher shutdown before the scheduled letter was published"))
+		}
+	}
+}
+
+// DelayedLetter copies the letter, readdressing it so the broker holds on to it for the delay.
+// The copy has no Deadline, PublishAt already checked it against the delivery time.
+func (pub *Publisher) delayedLetter(letter *models.Letter, delay time.Duration) (*models.Letter, error) {
+
+	delayed := *letter
+	envelope := *letter.Envelope
+	delayed.Envelope = &envelope
+	delayed.Deadline = time.Time{}
+
+	milliseconds := int64(delay / time.Millisecond)
+	if milliseconds < 1 {
+		milliseconds = 1
+	}
+
+	switch pub.delayConfig.Strategy {
+	case models.DelayExchangeHeader:
+		envelope.Headers = make(map[string]interface{}, len(letter.Envelope.Headers)+1)
+		for key, value := range letter.Envelope.Headers {
+			envelope.Headers[key] = value
+		}
+		envelope.Headers["x-delay"] = milliseconds
+
+	case models.DelayWaitQueue:
+		queueName, err := pub.waitQueue(letter.Envelope.Exchange, letter.Envelope.RoutingKey, pub.waitQueueDelay(milliseconds))
+		if err != nil {
+			return nil, err
+		}
+
+		envelope.Exchange = ""
+		envelope.RoutingKey = queueName
+
+	default:
+		return nil, fmt.Errorf("unknown delay strategy: %s", pub.delayConfig.Strategy)
+	}
+
+	return &delayed, nil
+}
+
+// WaitQueueDelay rounds the delay down to a wait queue tier, past the last tier to a multiple of it, and is 0 under
+// the first tier. Every delay doesn't get its own queue, what's left over is waited in-process so it's never late.
+func (pub *Publisher) waitQueueDelay(milliseconds int64) int64 {
+
+	tiers := pub.delayConfig.WaitQueueTiers
+	if len(tiers) == 0 {
+		tiers = defaultWaitQueueTiers
+	}
+
+	last := int64(tiers[len(tiers)-1])
+	if last < 1 {
+		return milliseconds
+	}
+
+	if milliseconds >= last {
+		return milliseconds / last * last
+	}
+
+	var rounded int64
+	for _, tier := range tiers {
+		if int64(tier) <= milliseconds {
+			rounded = int64(tier)
+		}
+	}
+
+	return rounded
+}
+
+// WaitQueue declares the wait queue that dead-letters to the exchange and routing key after milliseconds. The queue
+// expires (x-expires) once unused for twice its delay and a minute. Publishing doesn't count as using it so it's
+// declared again before a letter could outlive it. The declaration happens outside the scheduleLock so scheduled
+// letters don't queue up behind it; two letters racing to declare the same queue is harmless.
+func (pub *Publisher) waitQueue(exchange string, routingKey string, milliseconds int64) (string, error) {
+
+	prefix := pub.delayConfig.WaitQueuePrefix
+	if prefix == "" {
+		prefix = defaultWaitQueuePrefix
+	}
+
+	queueName := fmt.Sprintf("%s.%s.%s.%d", prefix, exchange, routingKey, milliseconds)
+
+	pub.scheduleLock.Lock()
+	declaredAt, declared := pub.waitQueues[queueName]
+	if declared && time.Since(declaredAt) < time.Duration(milliseconds)*time.Millisecond {
+		pub.scheduleLock.Unlock()
+		return queueName, nil
+	}
+
+	if pub.topologer == nil {
+		topologer, err := topology.NewTopologer(pub.ChannelPool)
+		if err != nil {
+			pub.scheduleLock.Unlock()
+			return "", err
+		}
+
+		pub.topologer = topologer
+	}
+
+	topologer := pub.topologer
+	pub.scheduleLock.Unlock()
+
+	err := topologer.CreateQueue(
+		queueName,
+		false, // passiveDeclare
+		true,  // durable
+		false, // autoDelete
+		false, // exclusive
+		false, // noWait
+		map[string]interface{}{
+			"x-message-ttl":             milliseconds,
+			"x-expires":                 2*milliseconds + int64(time.Minute/time.Millisecond),
+			"x-dead-letter-exchange":    exchange,
+			"x-dead-letter-routing-key": routingKey,
+		})
+	if err != nil {
+		return "", err
+	}
+
+	pub.scheduleLock.Lock()
+	pub.waitQueues[queueName] = time.Now()
+	pub.scheduleLock.Unlock()
+
+	return queueName, nil
+}
@@ -0,0 +1,37 @@
+package publisher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+)
+
+func TestWaitQueueDelay(t *testing.T) {
+
+	tests := []struct {
+		tiers    []uint32
+		delay    int64
+		expected int64
+	}{
+		{delay: 1, expected: 0}, // under the first tier
+		{delay: 999, expected: 0},
+		{delay: 1000, expected: 1000},
+		{delay: 1001, expected: 1000},
+		{delay: 9999, expected: 5000},
+		{delay: 61000, expected: 60000},
+		{delay: 600001, expected: 600000},
+		{delay: 3600000, expected: 3600000},
+		{delay: 7199999, expected: 3600000}, // multiples of the last tier
+		{delay: 7200001, expected: 7200000},
+		{tiers: []uint32{100, 200}, delay: 150, expected: 100},
+		{tiers: []uint32{100, 200}, delay: 450, expected: 400},
+		{tiers: []uint32{0}, delay: 450, expected: 450},
+	}
+
+	for _, test := range tests {
+		pub := &Publisher{delayConfig: &models.DelayConfig{WaitQueueTiers: test.tiers}}
+		assert.Equal(t, test.expected, pub.waitQueueDelay(test.delay), "delay %d, tiers %v", test.delay, test.tiers)
+	}
+}
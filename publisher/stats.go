@@ -0,0 +1,126 @@
+package publisher
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+)
+
+// LatencyBounds are the histogram bucket upper bounds, anything slower lands in the +Inf bucket.
+var latencyBounds = []time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// LatencyHistogram is a lock free histogram of durations using the latencyBounds.
+type latencyHistogram struct {
+	count   uint64
+	sum     uint64
+	max     uint64
+	buckets []uint64 // not cumulative, one more than latencyBounds
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{
+		buckets: make([]uint64, len(latencyBounds)+1),
+	}
+}
+
+func (lh *latencyHistogram) observe(duration time.Duration) {
+	if duration < 0 {
+		duration = 0
+	}
+
+	i := 0
+	for i < len(latencyBounds) && duration > latencyBounds[i] {
+		i++
+	}
+
+	atomic.AddUint64(&lh.buckets[i], 1)
+	atomic.AddUint64(&lh.count, 1)
+	atomic.AddUint64(&lh.sum, uint64(duration))
+
+	for {
+		max := atomic.LoadUint64(&lh.max)
+		if uint64(duration) <= max || atomic.CompareAndSwapUint64(&lh.max, max, uint64(duration)) {
+			return
+		}
+	}
+}
+
+func (lh *latencyHistogram) snapshot() *models.LatencyHistogram {
+	histogram := &models.LatencyHistogram{
+		Count:   atomic.LoadUint64(&lh.count),
+		Sum:     time.Duration(atomic.LoadUint64(&lh.sum)),
+		Max:     time.Duration(atomic.LoadUint64(&lh.max)),
+		Buckets: make([]*models.LatencyBucket, len(lh.buckets)),
+	}
+
+	var cumulative uint64
+	for i := range lh.buckets {
+		cumulative += atomic.LoadUint64(&lh.buckets[i])
+
+		bucket := &models.LatencyBucket{Count: cumulative}
+		if i < len(latencyBounds) {
+			bucket.UpperBound = latencyBounds[i]
+		}
+
+		histogram.Buckets[i] = bucket
+	}
+
+	return histogram
+}
+
+// PublisherStats holds the counters and histograms behind Publisher.Stats.
+type publisherStats struct {
+	published       uint64
+	failed          uint64
+	retried         uint64
+	queued          uint64
+	dropped         uint64
+	queueWait       *latencyHistogram
+	channelCheckout *latencyHistogram
+	publish         *latencyHistogram
+	confirm         *latencyHistogram
+}
+
+func newPublisherStats() *publisherStats {
+	return &publisherStats{
+		queueWait:       newLatencyHistogram(),
+		channelCheckout: newLatencyHistogram(),
+		publish:         newLatencyHistogram(),
+		confirm:         newLatencyHistogram(),
+	}
+}
+
+// Stats lets you know how many letters the Publisher handled and how long the steps of publishing took.
+// Counts Publish, PublishWithRetry, PublishAsync, AutoPublish, PublishAt and transactions since the Publisher was
+// created.
+func (pub *Publisher) Stats() *models.PublisherStats {
+	return &models.PublisherStats{
+		Published:       atomic.LoadUint64(&pub.stats.published),
+		Failed:          atomic.LoadUint64(&pub.stats.failed),
+		Retried:         atomic.LoadUint64(&pub.stats.retried),
+		Queued:          atomic.LoadUint64(&pub.stats.queued),
+		Dropped:         atomic.LoadUint64(&pub.stats.dropped),
+		QueueWait:       pub.stats.queueWait.snapshot(),
+		ChannelCheckout: pub.stats.channelCheckout.snapshot(),
+		Publish:         pub.stats.publish.snapshot(),
+		Confirm:         pub.stats.confirm.snapshot(),
+	}
+}
@@ -0,0 +1,230 @@
+package publisher
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+	"github.com/prom3t3us/turbocookedrabbit/pools"
+)
+
+// Tx publishes letters inside an AMQP transaction, see Publisher.Transaction.
+type Tx struct {
+	pub      *Publisher
+	chanHost *pools.ChannelHost
+	staged   []*stagedLetter
+	start    time.Time
+	err      error
+}
+
+// StagedLetter is a letter published in a transaction and what was actually sent for it.
+type stagedLetter struct {
+	letter   *models.Letter
+	outgoing *models.Letter
+}
+
+// Transaction publishes every letter handed to tx.Publish atomically: all of them are committed when fn returns nil,
+// none of them when fn (or a tx.Publish) returns an error or panics. A channel can't leave transaction mode so the
+// Publisher leases one from the ChannelPool and keeps it, out of the pool, for the next Transaction until Shutdown.
+// Concurrent transactions lease one channel each, the extra channels are closed and replaced afterwards.
+// Notifications (and AfterPublish hooks) for the letters are sent once the transaction commits or rolls back.
+func (pub *Publisher) Transaction(fn func(tx *Tx) error) (err error) {
+
+	chanHost, err := pub.getTxChannel()
+	if err != nil {
+		return err
+	}
+
+	healthy := false // only a channel whose transaction settled is kept
+	defer func() { pub.releaseTxChannel(chanHost, healthy) }()
+
+	tx := &Tx{
+		pub:      pub,
+		chanHost: chanHost,
+		start:    time.Now(),
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			_ = chanHost.Channel.TxRollback()
+			tx.finish(fmt.Errorf("transaction rolled back: %v", recovered))
+			panic(recovered)
+		}
+	}()
+
+	err = fn(tx)
+	if err == nil {
+		err = tx.err // a failed tx.Publish fails the transaction even when fn ignored it
+	}
+
+	if err != nil {
+		rollbackErr := chanHost.Channel.TxRollback()
+		if rollbackErr != nil {
+			err = fmt.Errorf("%s (rollback failed: %s)", err, rollbackErr)
+		}
+
+		healthy = rollbackErr == nil && tx.err == nil
+		tx.finish(err)
+		return err
+	}
+
+	if err = chanHost.Channel.TxCommit(); err != nil {
+		tx.finish(err)
+		return err
+	}
+
+	healthy = true
+	tx.finish(nil)
+	return nil
+}
+
+// GetTxChannel takes the channel kept by an earlier Transaction, or leases one and puts it in transaction mode.
+func (pub *Publisher) getTxChannel() (*pools.ChannelHost, error) {
+
+	pub.txLock.Lock()
+	chanHost := pub.txChannel
+	pub.txChannel = nil
+	pub.txLock.Unlock()
+
+	if chanHost != nil {
+		select {
+		case <-chanHost.CloseErrors():
+			pub.discardTxChannel(chanHost)
+		default:
+			return chanHost, nil
+		}
+	}
+
+	checkoutStart := time.Now()
+	chanHost, err := pub.ChannelPool.GetChannel()
+	pub.stats.channelCheckout.observe(time.Since(checkoutStart))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = chanHost.Channel.Tx(); err != nil {
+		pub.discardTxChannel(chanHost)
+		return nil, err
+	}
+
+	return chanHost, nil
+}
+
+// ReleaseTxChannel keeps a healthy channel for the next Transaction, unless one is already kept.
+func (pub *Publisher) releaseTxChannel(chanHost *pools.ChannelHost, healthy bool) {
+
+	if healthy {
+		pub.txLock.Lock()
+		kept := pub.txChannel == nil
+		if kept {
+			pub.txChannel = chanHost
+		}
+		pub.txLock.Unlock()
+
+		if kept {
+			return
+		}
+	}
+
+	pub.discardTxChannel(chanHost)
+}
+
+// CloseTxChannel discards the channel kept for transactions.
+func (pub *Publisher) closeTxChannel() {
+
+	pub.txLock.Lock()
+	chanHost := pub.txChannel
+	pub.txChannel = nil
+	pub.txLock.Unlock()
+
+	if chanHost != nil {
+		pub.discardTxChannel(chanHost)
+	}
+}
+
+// DiscardTxChannel closes a channel in transaction mode and has the ChannelPool replace it.
+func (pub *Publisher) discardTxChannel(chanHost *pools.ChannelHost) {
+	pub.ChannelPool.FlagChannel(chanHost.ChannelID) // flagged first so nobody gets it back in transaction mode
+	_ = chanHost.Channel.Close()
+	pub.ChannelPool.ReturnChannel(chanHost, false)
+}
+
+// Publish publishes the letter as part of the transaction, it is only delivered when the transaction commits.
+// Letters go through the same steps as Publish (MessageID, dedupe, rate limit, hooks, claim-check, chunking).
+// A letter expired or refused by a BeforePublish hook is reported right away and left out of the transaction.
+// Once a Publish fails the transaction can only roll back.
+func (tx *Tx) Publish(letter *models.Letter) error {
+
+	if tx.err != nil {
+		return tx.err
+	}
+
+	pub := tx.pub
+	if !pub.acceptLetter(letter) {
+		return fmt.Errorf("letter with MessageID %s was already submitted", letter.MessageID)
+	}
+
+	if pub.rateLimiter != nil {
+		pub.rateLimiter.wait(letter)
+	}
+
+	start := time.Now()
+	if letter.Expired() {
+		err := pub.dropExpiredLetter(letter)
+		pub.middleware.runAfter(letter, err, time.Since(start))
+		return err
+	}
+
+	if err := pub.middleware.runBefore(letter); err != nil {
+		atomic.AddUint64(&pub.stats.failed, 1)
+		pub.releaseLetter(letter)
+		pub.sendFailureToNotifications(letter, models.FailureRejected, err)
+		pub.middleware.runAfter(letter, err, time.Since(start))
+		return err
+	}
+
+	outgoing, err := pub.claimCheck(letter)
+	if err != nil {
+		atomic.AddUint64(&pub.stats.failed, 1)
+		pub.releaseLetter(letter)
+		pub.sendToNotifications(letter, err)
+		pub.middleware.runAfter(letter, err, time.Since(start))
+		return err
+	}
+
+	tx.staged = append(tx.staged, &stagedLetter{letter: letter, outgoing: outgoing})
+
+	publishStart := time.Now()
+	err = pub.publishLetter(tx.chanHost.Channel, outgoing)
+	pub.stats.publish.observe(time.Since(publishStart))
+	if err != nil {
+		tx.err = err
+		return err
+	}
+
+	return nil
+}
+
+// Finish reports the result of the transaction for every letter published in it.
+func (tx *Tx) finish(err error) {
+	duration := time.Since(tx.start)
+
+	counter := &tx.pub.stats.published
+	if err != nil {
+		counter = &tx.pub.stats.failed
+	}
+
+	for _, staged := range tx.staged {
+		atomic.AddUint64(counter, 1)
+		if err != nil {
+			tx.pub.releaseLetter(staged.letter)
+			tx.pub.releaseClaimCheck(staged.letter, staged.outgoing)
+		}
+
+		tx.pub.sendToNotifications(staged.letter, err)
+		tx.pub.middleware.runAfter(staged.letter, err, duration)
+	}
+
+	tx.staged = nil
+}
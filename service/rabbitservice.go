@@ -2,10 +2,10 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"sync"
 	"sync/atomic"
-	"time"
 
 	"github.com/prom3t3us/turbocookedrabbit/consumer"
 	"github.com/prom3t3us/turbocookedrabbit/models"
@@ -24,11 +24,11 @@ type RabbitService struct {
 	encryptionConfigured bool
 	centralErr           chan error
 	consumers            map[string]*consumer.Consumer
-	stopServiceSignal    chan bool
-	stop                 bool
+	stopServiceSignal    chan struct{}
+	started              bool
+	monitorGroup         *sync.WaitGroup
 	retryCount           uint32
 	letterCount          uint64
-	monitorSleepInterval time.Duration
 	serviceLock          *sync.Mutex
 }
 
@@ -51,16 +51,15 @@ func NewRabbitService(config *models.RabbitSeasoning) (*RabbitService, error) {
 	}
 
 	rs := &RabbitService{
-		ChannelPool:          channelPool,
-		Config:               config,
-		Publisher:            publisher,
-		Topologer:            topologer,
-		centralErr:           make(chan error, config.ServiceConfig.ErrorBuffer),
-		stopServiceSignal:    make(chan bool, 1),
-		consumers:            make(map[string]*consumer.Consumer),
-		retryCount:           10,
-		monitorSleepInterval: time.Duration(3) * time.Second,
-		serviceLock:          &sync.Mutex{},
+		ChannelPool:  channelPool,
+		Config:       config,
+		Publisher:    publisher,
+		Topologer:    topologer,
+		centralErr:   make(chan error, config.ServiceConfig.ErrorBuffer),
+		consumers:    make(map[string]*consumer.Consumer),
+		monitorGroup: &sync.WaitGroup{},
+		retryCount:   10,
+		serviceLock:  &sync.Mutex{},
 	}
 
 	err = rs.CreateConsumers(config.ConsumerConfigs)
@@ -93,14 +92,28 @@ func (rs *RabbitService) CreateConsumers(consumerConfigs map[string]*models.Cons
 }
 
 // CreateConsumerFromConfig takes a config from the Config map and builds a consumer (errors if config is missing).
+// Errors when the service already has a consumer by that name, it would keep running unmonitored once replaced.
 func (rs *RabbitService) CreateConsumerFromConfig(consumerName string) error {
 
 	if consumerConfig, ok := rs.Config.ConsumerConfigs[consumerName]; ok {
+		rs.serviceLock.Lock()
+		defer rs.serviceLock.Unlock()
+
+		if _, exists := rs.consumers[consumerName]; exists {
+			return fmt.Errorf("consumer %s already exists", consumerName)
+		}
+
 		consumer, err := consumer.NewConsumerFromConfig(consumerConfig, rs.ChannelPool)
 		if err != nil {
 			return err
 		}
+
 		rs.consumers[consumerName] = consumer
+		if rs.started { // monitor consumers added after the service started too
+			rs.monitorGroup.Add(1)
+			go rs.collectConsumerErrors(consumer, rs.stopServiceSignal)
+		}
+
 		return nil
 	}
 	return nil
@@ -212,75 +225,70 @@ func (rs *RabbitService) Publish(input interface{}, exchangeName, routingKey str
 // StartService gets all the background internals and logging/monitoring started.
 func (rs *RabbitService) StartService(allowRetry bool) {
 
-	// Start the background monitors and logging.
-	go rs.collectChannelPoolErrors()
-	go rs.collectConsumerErrors()
-	go rs.monitorStopService()
-
-	// Start the AutoPublisher
-	rs.Publisher.StartAutoPublish(allowRetry)
-}
+	rs.serviceLock.Lock()
+	if !rs.started {
+		rs.stopServiceSignal = make(chan struct{})
+		rs.started = true
 
-func (rs *RabbitService) monitorStopService() {
+		// Start the background monitors and logging.
+		rs.monitorGroup.Add(1)
+		go rs.collectChannelPoolErrors(rs.stopServiceSignal)
 
-MonitorLoop:
-	for {
-		select {
-		case <-rs.stopServiceSignal:
-			rs.stop = true
-			break MonitorLoop
-		default:
-			time.Sleep(rs.monitorSleepInterval)
-			break
+		for _, consumer := range rs.consumers {
+			rs.monitorGroup.Add(1)
+			go rs.collectConsumerErrors(consumer, rs.stopServiceSignal)
 		}
 	}
+	rs.serviceLock.Unlock()
+
+	// Start the AutoPublisher
+	rs.Publisher.StartAutoPublish(allowRetry)
 }
 
-func (rs *RabbitService) collectChannelPoolErrors() {
+func (rs *RabbitService) collectChannelPoolErrors(stop <-chan struct{}) {
+	defer rs.monitorGroup.Done()
 
-MonitorLoop:
 	for {
-		if rs.stop {
-			break MonitorLoop
-		}
-
 		select {
+		case <-stop:
+			return
 		case err := <-rs.ChannelPool.Errors():
-			rs.centralErr <- err
-		default:
-			time.Sleep(rs.monitorSleepInterval)
-			break
+			if !rs.sendToCentralErr(err, stop) {
+				return
+			}
 		}
 	}
 }
 
-func (rs *RabbitService) collectConsumerErrors() {
+func (rs *RabbitService) collectConsumerErrors(consumer *consumer.Consumer, stop <-chan struct{}) {
+	defer rs.monitorGroup.Done()
 
-MonitorLoop:
 	for {
-
-		for _, consumer := range rs.consumers {
-		IndividualConsumerLoop:
-			for {
-				if rs.stop {
-					break MonitorLoop
-				}
-
-				select {
-				case err := <-consumer.Errors():
-					rs.centralErr <- err
-				default:
-					break IndividualConsumerLoop
-				}
+		select {
+		case <-stop:
+			return
+		case err := <-consumer.Errors():
+			if !rs.sendToCentralErr(err, stop) {
+				return
 			}
 		}
+	}
+}
 
-		time.Sleep(rs.monitorSleepInterval)
+// SendToCentralErr waits for room in CentralErr and returns false if the service stopped first.
+func (rs *RabbitService) sendToCentralErr(err error, stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return false
+	case rs.centralErr <- err:
+		return true
 	}
 }
 
 // GetConsumer allows you to get the individual consumer.
 func (rs *RabbitService) GetConsumer(consumerName string) (*consumer.Consumer, error) {
+	rs.serviceLock.Lock()
+	defer rs.serviceLock.Unlock()
 
 	if consumer, ok := rs.consumers[consumerName]; ok {
 		return consumer, nil
@@ -289,14 +297,41 @@ func (rs *RabbitService) GetConsumer(consumerName string) (*consumer.Consumer, e
 	return nil, errors.New("consumer was not found")
 }
 
+// PauseConsumers pauses every consumer of the service, they stop receiving new deliveries but keep their channel and
+// handle the messages already received (see Consumer.Pause).
+func (rs *RabbitService) PauseConsumers() {
+	rs.serviceLock.Lock()
+	defer rs.serviceLock.Unlock()
+
+	for _, consumer := range rs.consumers {
+		consumer.Pause()
+	}
+}
+
+// ResumeConsumers resumes every consumer of the service paused by PauseConsumers (or Consumer.Pause).
+func (rs *RabbitService) ResumeConsumers() {
+	rs.serviceLock.Lock()
+	defer rs.serviceLock.Unlock()
+
+	for _, consumer := range rs.consumers {
+		consumer.Resume()
+	}
+}
+
 // StopService stops the AutoPublisher, Consumer, and Monitoring.
+// Blocks until the monitors have stopped.
 func (rs *RabbitService) StopService() {
 
 	rs.Publisher.StopAutoPublish()
 
-	time.Sleep(1 * time.Second)
-	rs.stopServiceSignal <- true
-	time.Sleep(1 * time.Second)
+	rs.serviceLock.Lock()
+	if rs.started {
+		close(rs.stopServiceSignal)
+		rs.started = false
+	}
+	rs.serviceLock.Unlock()
+
+	rs.monitorGroup.Wait()
 }
 
 // Shutdown stops the service and shuts down the ChannelPool.
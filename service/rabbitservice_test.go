@@ -54,6 +54,18 @@ func TestSetHashForEncryption(t *testing.T) {
 	assert.NotEqual(t, 0, Service.Config.EncryptionConfig.Hashkey)
 }
 
+func TestCreateConsumerFromConfigExisting(t *testing.T) {
+
+	existing, err := Service.GetConsumer("TurboCookedRabbitConsumer-Ackable")
+	assert.NoError(t, err)
+
+	assert.Error(t, Service.CreateConsumerFromConfig("TurboCookedRabbitConsumer-Ackable"))
+
+	consumer, err := Service.GetConsumer("TurboCookedRabbitConsumer-Ackable")
+	assert.NoError(t, err)
+	assert.Equal(t, existing, consumer)
+}
+
 func TestPublishWithoutWrap(t *testing.T) {
 
 	Config.EncryptionConfig.Enabled = false
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+)
+
+// BlobStore keeps payloads too large to publish (claim-check) until consumers fetch them.
+type BlobStore interface {
+	Put(key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error) // gives up once ctx is done
+	Delete(key string) error
+}
+
+// FileBlobStore is a BlobStore keeping each payload as a file in a directory (local or a shared mount).
+type FileBlobStore struct {
+	Directory string
+}
+
+// NewFileBlobStore creates a FileBlobStore, creating the directory if needed.
+func NewFileBlobStore(directory string) (*FileBlobStore, error) {
+
+	if directory == "" {
+		return nil, errors.New("blob store directory can't be empty")
+	}
+
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FileBlobStore{
+		Directory: directory,
+	}, nil
+}
+
+// Put writes the payload under key, replacing any payload already there.
+func (fbs *FileBlobStore) Put(key string, data []byte) error {
+
+	path, err := fbs.path(key)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomically(path, data)
+}
+
+// Get reads the payload kept under key.
+func (fbs *FileBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	path, err := fbs.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(path)
+}
+
+// Delete removes the payload kept under key, deleting a missing payload is not an error.
+func (fbs *FileBlobStore) Delete(key string) error {
+
+	path, err := fbs.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Path gets the file for a key, keys can't contain path separators so they stay inside the Directory.
+func (fbs *FileBlobStore) path(key string) (string, error) {
+	return keyFilePath(fbs.Directory, key, "", "blob")
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileBlobStore(t *testing.T) {
+
+	directory, err := ioutil.TempDir("", "TurboCookedRabbitBlobs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(directory)
+
+	store, err := NewFileBlobStore(directory)
+	assert.NoError(t, err)
+
+	data := RandomBytes(2048)
+	assert.NoError(t, store.Put("BlobKey", data))
+
+	stored, err := store.Get(context.Background(), "BlobKey")
+	assert.NoError(t, err)
+	assert.Equal(t, data, stored)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = store.Get(cancelled, "BlobKey")
+	assert.Equal(t, context.Canceled, err)
+
+	assert.NoError(t, store.Delete("BlobKey"))
+	assert.NoError(t, store.Delete("BlobKey"))
+
+	_, err = store.Get(context.Background(), "BlobKey")
+	assert.Error(t, err)
+
+	// Keys can't escape the directory.
+	assert.Error(t, store.Put("../BlobKey", data))
+	assert.Error(t, store.Put("", data))
+}
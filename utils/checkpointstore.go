@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// CheckpointStore keeps the offset a stream consumer processed up to so it resumes from there after a restart.
+type CheckpointStore interface {
+	Save(key string, offset int64) error
+	Load(key string) (offset int64, ok bool, err error)
+}
+
+// FileCheckpointStore is a CheckpointStore keeping each checkpoint as a file in a directory.
+type FileCheckpointStore struct {
+	Directory string
+}
+
+// NewFileCheckpointStore creates a FileCheckpointStore, creating the directory if needed.
+func NewFileCheckpointStore(directory string) (*FileCheckpointStore, error) {
+
+	if directory == "" {
+		return nil, errors.New("checkpoint store directory can't be empty")
+	}
+
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FileCheckpointStore{
+		Directory: directory,
+	}, nil
+}
+
+// Save writes the offset under key, replacing the checkpoint already there.
+func (fcs *FileCheckpointStore) Save(key string, offset int64) error {
+
+	path, err := fcs.path(key)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomically(path, []byte(strconv.FormatInt(offset, 10)))
+}
+
+// Load reads the offset kept under key, ok is false when there's no checkpoint yet.
+func (fcs *FileCheckpointStore) Load(key string) (int64, bool, error) {
+
+	path, err := fcs.path(key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, false, nil
+		}
+
+		return 0, false, err
+	}
+
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false, errors.New("invalid checkpoint " + key + ": " + err.Error())
+	}
+
+	return offset, true, nil
+}
+
+// Path gets the file for a key, keys can't contain path separators so they stay inside the Directory.
+func (fcs *FileCheckpointStore) path(key string) (string, error) {
+	return keyFilePath(fcs.Directory, key, ".offset", "checkpoint")
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileCheckpointStore(t *testing.T) {
+
+	directory, err := ioutil.TempDir("", "TurboCookedRabbitCheckpoints")
+	assert.NoError(t, err)
+	defer os.RemoveAll(directory)
+
+	store, err := NewFileCheckpointStore(directory)
+	assert.NoError(t, err)
+
+	_, ok, err := store.Load("StreamConsumer")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, store.Save("StreamConsumer", 41))
+	assert.NoError(t, store.Save("StreamConsumer", 42))
+
+	offset, ok, err := store.Load("StreamConsumer")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), offset)
+
+	// Still there for a new store on the same directory.
+	store, err = NewFileCheckpointStore(directory)
+	assert.NoError(t, err)
+
+	offset, ok, err = store.Load("StreamConsumer")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), offset)
+
+	// Keys can't escape the directory.
+	assert.Error(t, store.Save("../StreamConsumer", 1))
+	assert.Error(t, store.Save("", 1))
+}
@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+)
+
+// Dispatcher delivers items to a buffered channel without spawning goroutines, following an OverflowPolicy
+// when the buffer is full. A handler can be set to receive every item synchronously instead of the channel.
+type Dispatcher struct {
+	target          dispatchTarget
+	policy          models.OverflowPolicy
+	handler         func(interface{})
+	overflowHandler func(interface{})
+	dropped         uint64
+	handlerLock     *sync.RWMutex
+}
+
+type dispatchTarget interface {
+	trySend(item interface{}) bool
+	send(item interface{})
+	tryReceive() bool
+}
+
+type errorTarget chan error
+
+func (target errorTarget) trySend(item interface{}) bool {
+	err, _ := item.(error)
+	select {
+	case target <- err:
+		return true
+	default:
+		return false
+	}
+}
+
+func (target errorTarget) send(item interface{}) {
+	err, _ := item.(error)
+	target <- err
+}
+
+func (target errorTarget) tryReceive() bool {
+	select {
+	case <-target:
+		return true
+	default:
+		return false
+	}
+}
+
+type notificationTarget chan *models.Notification
+
+func (target notificationTarget) trySend(item interface{}) bool {
+	notification, _ := item.(*models.Notification)
+	select {
+	case target <- notification:
+		return true
+	default:
+		return false
+	}
+}
+
+func (target notificationTarget) send(item interface{}) {
+	notification, _ := item.(*models.Notification)
+	target <- notification
+}
+
+func (target notificationTarget) tryReceive() bool {
+	select {
+	case <-target:
+		return true
+	default:
+		return false
+	}
+}
+
+// NewErrorDispatcher creates a Dispatcher for a buffered error channel.
+func NewErrorDispatcher(errors chan error, policy models.OverflowPolicy) *Dispatcher {
+	return newDispatcher(errorTarget(errors), policy)
+}
+
+// NewNotificationDispatcher creates a Dispatcher for a buffered Notification channel.
+func NewNotificationDispatcher(notifications chan *models.Notification, policy models.OverflowPolicy) *Dispatcher {
+	return newDispatcher(notificationTarget(notifications), policy)
+}
+
+func newDispatcher(target dispatchTarget, policy models.OverflowPolicy) *Dispatcher {
+	if policy == "" {
+		policy = models.OverflowDropOldest
+	}
+
+	return &Dispatcher{
+		target:      target,
+		policy:      policy,
+		handlerLock: &sync.RWMutex{},
+	}
+}
+
+// Dispatch delivers the item to the handler if one is set, otherwise to the channel following the OverflowPolicy.
+func (d *Dispatcher) Dispatch(item interface{}) {
+	d.handlerLock.RLock()
+	handler := d.handler
+	overflowHandler := d.overflowHandler
+	d.handlerLock.RUnlock()
+
+	if handler != nil {
+		handler(item)
+		return
+	}
+
+	if d.target.trySend(item) {
+		return
+	}
+
+	switch d.policy {
+	case models.OverflowBlock:
+		d.target.send(item)
+	case models.OverflowDropNewest:
+		atomic.AddUint64(&d.dropped, 1)
+	case models.OverflowCallback:
+		if overflowHandler != nil {
+			overflowHandler(item)
+			return
+		}
+
+		atomic.AddUint64(&d.dropped, 1)
+	default:
+		d.dropOldest(item)
+	}
+}
+
+// DropOldest makes room for the item by dropping the oldest buffered items.
+func (d *Dispatcher) dropOldest(item interface{}) {
+	for {
+		if d.target.trySend(item) {
+			return
+		}
+
+		// Nothing to drop (unbuffered or a reader got there first), try once more before giving up on the item.
+		if !d.target.tryReceive() {
+			if !d.target.trySend(item) {
+				atomic.AddUint64(&d.dropped, 1)
+			}
+			return
+		}
+
+		atomic.AddUint64(&d.dropped, 1)
+	}
+}
+
+// SetHandler routes every item to handler, synchronously, instead of the channel. Nil restores the channel.
+func (d *Dispatcher) SetHandler(handler func(interface{})) {
+	d.handlerLock.Lock()
+	defer d.handlerLock.Unlock()
+
+	d.handler = handler
+}
+
+// SetOverflowHandler sets the handler used by the Callback OverflowPolicy for items that don't fit in the channel.
+func (d *Dispatcher) SetOverflowHandler(handler func(interface{})) {
+	d.handlerLock.Lock()
+	defer d.handlerLock.Unlock()
+
+	d.overflowHandler = handler
+}
+
+// SetErrorHandler is SetHandler for a Dispatcher of errors.
+func (d *Dispatcher) SetErrorHandler(handler func(error)) {
+	d.SetHandler(errorHandler(handler))
+}
+
+// SetErrorOverflowHandler is SetOverflowHandler for a Dispatcher of errors.
+func (d *Dispatcher) SetErrorOverflowHandler(handler func(error)) {
+	d.SetOverflowHandler(errorHandler(handler))
+}
+
+// SetNotificationHandler is SetHandler for a Dispatcher of Notifications.
+func (d *Dispatcher) SetNotificationHandler(handler func(*models.Notification)) {
+	d.SetHandler(notificationHandler(handler))
+}
+
+// SetNotificationOverflowHandler is SetOverflowHandler for a Dispatcher of Notifications.
+func (d *Dispatcher) SetNotificationOverflowHandler(handler func(*models.Notification)) {
+	d.SetOverflowHandler(notificationHandler(handler))
+}
+
+func errorHandler(handler func(error)) func(interface{}) {
+	if handler == nil {
+		return nil
+	}
+
+	return func(item interface{}) { handler(item.(error)) }
+}
+
+func notificationHandler(handler func(*models.Notification)) func(interface{}) {
+	if handler == nil {
+		return nil
+	}
+
+	return func(item interface{}) { handler(item.(*models.Notification)) }
+}
+
+// Dropped lets you know how many items have been dropped because the channel was full.
+func (d *Dispatcher) Dropped() uint64 {
+	return atomic.LoadUint64(&d.dropped)
+}
+
+// Policy lets you know which OverflowPolicy the Dispatcher is following.
+func (d *Dispatcher) Policy() models.OverflowPolicy {
+	return d.policy
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prom3t3us/turbocookedrabbit/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcherDropOldest(t *testing.T) {
+
+	notifications := make(chan *models.Notification, 2)
+	dispatcher := NewNotificationDispatcher(notifications, "")
+	assert.Equal(t, models.OverflowDropOldest, dispatcher.Policy()) // the default
+
+	for i := uint64(0); i < 5; i++ {
+		dispatcher.Dispatch(&models.Notification{LetterID: i})
+	}
+
+	assert.Equal(t, uint64(3), dispatcher.Dropped())
+	assert.Equal(t, uint64(3), (<-notifications).LetterID)
+	assert.Equal(t, uint64(4), (<-notifications).LetterID)
+}
+
+func TestDispatcherDropNewest(t *testing.T) {
+
+	errs := make(chan error, 2)
+	dispatcher := NewErrorDispatcher(errs, models.OverflowDropNewest)
+
+	for i := 0; i < 5; i++ {
+		dispatcher.Dispatch(errors.New(string(rune('a' + i))))
+	}
+
+	assert.Equal(t, uint64(3), dispatcher.Dropped())
+	assert.Equal(t, "a", (<-errs).Error())
+	assert.Equal(t, "b", (<-errs).Error())
+}
+
+func TestDispatcherBlock(t *testing.T) {
+
+	errs := make(chan error, 1)
+	dispatcher := NewErrorDispatcher(errs, models.OverflowBlock)
+
+	dispatcher.Dispatch(errors.New("first"))
+
+	done := make(chan struct{})
+	go func() {
+		dispatcher.Dispatch(errors.New("second"))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("dispatch should block while the channel is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.Equal(t, "first", (<-errs).Error())
+	<-done
+	assert.Equal(t, "second", (<-errs).Error())
+	assert.Equal(t, uint64(0), dispatcher.Dropped())
+}
+
+func TestDispatcherCallback(t *testing.T) {
+
+	errs := make(chan error, 1)
+	dispatcher := NewErrorDispatcher(errs, models.OverflowCallback)
+
+	dispatcher.Dispatch(errors.New("first"))
+	dispatcher.Dispatch(errors.New("dropped"))
+	assert.Equal(t, uint64(1), dispatcher.Dropped())
+
+	overflowed := make([]interface{}, 0)
+	dispatcher.SetOverflowHandler(func(item interface{}) { overflowed = append(overflowed, item) })
+	dispatcher.Dispatch(errors.New("overflowed"))
+
+	assert.Equal(t, uint64(1), dispatcher.Dropped())
+	assert.Equal(t, 1, len(overflowed))
+	assert.Equal(t, "overflowed", overflowed[0].(error).Error())
+	assert.Equal(t, "first", (<-errs).Error())
+}
+
+func TestDispatcherHandler(t *testing.T) {
+
+	notifications := make(chan *models.Notification, 1)
+	dispatcher := NewNotificationDispatcher(notifications, models.OverflowDropNewest)
+
+	handled := make([]*models.Notification, 0)
+	dispatcher.SetHandler(func(item interface{}) { handled = append(handled, item.(*models.Notification)) })
+
+	for i := uint64(0); i < 3; i++ {
+		dispatcher.Dispatch(&models.Notification{LetterID: i})
+	}
+
+	assert.Equal(t, 3, len(handled))
+	assert.Equal(t, 0, len(notifications))
+
+	dispatcher.SetHandler(nil)
+	dispatcher.Dispatch(&models.Notification{LetterID: 3})
+	assert.Equal(t, uint64(3), (<-notifications).LetterID)
+	assert.Equal(t, uint64(0), dispatcher.Dropped())
+}
+
+func TestDispatcherTypedHandlers(t *testing.T) {
+
+	errs := make(chan error, 1)
+	errorDispatcher := NewErrorDispatcher(errs, models.OverflowCallback)
+
+	handledErrors := make([]error, 0)
+	errorDispatcher.SetErrorHandler(func(err error) { handledErrors = append(handledErrors, err) })
+	errorDispatcher.Dispatch(errors.New("handled"))
+	assert.Equal(t, 1, len(handledErrors))
+
+	errorDispatcher.SetErrorHandler(nil)
+	overflowedErrors := make([]error, 0)
+	errorDispatcher.SetErrorOverflowHandler(func(err error) { overflowedErrors = append(overflowedErrors, err) })
+	errorDispatcher.Dispatch(errors.New("first"))
+	errorDispatcher.Dispatch(errors.New("overflowed"))
+	assert.Equal(t, "overflowed", overflowedErrors[0].Error())
+	assert.Equal(t, "first", (<-errs).Error())
+
+	notifications := make(chan *models.Notification, 1)
+	notificationDispatcher := NewNotificationDispatcher(notifications, models.OverflowCallback)
+
+	handled := make([]*models.Notification, 0)
+	notificationDispatcher.SetNotificationHandler(func(notification *models.Notification) {
+		handled = append(handled, notification)
+	})
+	notificationDispatcher.Dispatch(&models.Notification{LetterID: 1})
+	assert.Equal(t, uint64(1), handled[0].LetterID)
+
+	notificationDispatcher.SetNotificationHandler(nil)
+	overflowed := make([]*models.Notification, 0)
+	notificationDispatcher.SetNotificationOverflowHandler(func(notification *models.Notification) {
+		overflowed = append(overflowed, notification)
+	})
+	notificationDispatcher.Dispatch(&models.Notification{LetterID: 2})
+	notificationDispatcher.Dispatch(&models.Notification{LetterID: 3})
+	assert.Equal(t, uint64(3), overflowed[0].LetterID)
+	assert.Equal(t, uint64(2), (<-notifications).LetterID)
+	assert.Equal(t, uint64(0), notificationDispatcher.Dropped())
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// KeyFilePath gets the file for a key of a directory store (kind names the key in errors). Keys can't contain path
+// separators so they stay inside the directory.
+func keyFilePath(directory string, key string, extension string, kind string) (string, error) {
+
+	if key == "" || key == "." || key == ".." || filepath.Base(key) != key {
+		return "", errors.New("invalid " + kind + " key: " + key)
+	}
+
+	return filepath.Join(directory, key+extension), nil
+}
+
+// WriteFileAtomically writes to a temporary file first then renames it, so a reader never sees a partial file and a
+// crash never leaves one.
+func writeFileAtomically(path string, data []byte) error {
+
+	tempPath := path + ".tmp"
+	if err := ioutil.WriteFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewUUID creates a random (version 4) UUID in its canonical 36 character form.
+func NewUUID() string {
+
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+
+	id[6] = (id[6] & 0x0f) | 0x40 // version 4
+	id[8] = (id[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	buffer := make([]byte, 36)
+	hex.Encode(buffer[0:8], id[0:4])
+	buffer[8] = '-'
+	hex.Encode(buffer[9:13], id[4:6])
+	buffer[13] = '-'
+	hex.Encode(buffer[14:18], id[6:8])
+	buffer[18] = '-'
+	hex.Encode(buffer[19:23], id[8:10])
+	buffer[23] = '-'
+	hex.Encode(buffer[24:], id[10:])
+
+	return string(buffer)
+}
+
+// NewULID creates a ULID for the current time, a 26 character id that sorts by creation time (to the millisecond).
+func NewULID() string {
+	return NewULIDAt(time.Now())
+}
+
+// NewULIDAt creates a ULID for the time provided.
+// Source: https://github.com/ulid/spec
+func NewULIDAt(t time.Time) string {
+
+	var id [16]byte
+
+	ms := uint64(t.UnixNano() / int64(time.Millisecond))
+	id[0] = byte(ms >> 40)
+	id[1] = byte(ms >> 32)
+	id[2] = byte(ms >> 24)
+	id[3] = byte(ms >> 16)
+	id[4] = byte(ms >> 8)
+	id[5] = byte(ms)
+
+	_, _ = rand.Read(id[6:])
+
+	// 128 bits as 26 base32 characters, the first character only holds the top 3 bits.
+	buffer := make([]byte, 26)
+	buffer[0] = crockfordBase32[(id[0]&224)>>5]
+	for i := 1; i < 26; i++ {
+		bit := 3 + (i-1)*5 // position of the character's first bit
+		value := (uint16(id[bit/8]) << 8) | uint16(nextByte(id[:], bit/8+1))
+		buffer[i] = crockfordBase32[(value>>(11-uint(bit%8)))&31]
+	}
+
+	return string(buffer)
+}
+
+func nextByte(id []byte, index int) byte {
+	if index < len(id) {
+		return id[index]
+	}
+
+	return 0
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUUID(t *testing.T) {
+
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	id := NewUUID()
+	t.Logf("UUID: %s", id)
+	assert.Regexp(t, uuidPattern, id)
+	assert.NotEqual(t, id, NewUUID())
+}
+
+func TestNewULID(t *testing.T) {
+
+	ulidPattern := regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+
+	id := NewULID()
+	t.Logf("ULID: %s", id)
+	assert.Regexp(t, ulidPattern, id)
+	assert.NotEqual(t, id, NewULID())
+
+	// The spec's example timestamp, 1469918176385 ms, encodes as 01ARYZ6S41.
+	id = NewULIDAt(time.Unix(0, 1469918176385*int64(time.Millisecond)))
+	assert.Equal(t, "01ARYZ6S41", id[:10])
+
+	// Later timestamps sort after earlier ones.
+	earlier := NewULIDAt(time.Now())
+	later := NewULIDAt(time.Now().Add(time.Millisecond))
+	assert.True(t, earlier[:10] < later[:10])
+}